@@ -1,54 +1,22 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ClarkGuan/go-sdl2/sdl"
 	"github.com/ClarkGuan/scrcpy-go/scrcpy"
-	"gopkg.in/yaml.v2"
 )
 
-type EntryFile struct {
-	Entries []*Entry  `yaml:"keys"`
-	Args    []*Arg    `yaml:"args"`
-	Hits    []int     `yaml:"hits"`
-	Stables []*Stable `yaml:"stable"`
-}
-
-type Stable struct {
-	Pixel int `yaml:"pixel"`
-	Delay int `yaml:"delay"`
-}
-
-type Arg struct {
-	Name  string `yaml:"name"`
-	Value string `yaml:"value"`
-}
-
-type Entry struct {
-	Code        string        `yaml:"code"`
-	Point       *EntryPoint   `yaml:"point"`
-	Comment     string        `yaml:"comment"`
-	Macro       []*EntryMacro `yaml:"macro"`
-	ShowPointer bool          `yaml:"show_pointer"`
-	Type        string        `yaml:"type"`
-}
-
-type EntryPoint struct {
-	X int `yaml:"x"`
-	Y int `yaml:"y"`
-}
-
-type EntryMacro struct {
-	Point *EntryPoint `yaml:"point"`
-	Delay int         `yaml:"delay"`
-}
-
 func main() {
 	log.Printf("SDL %d.%d.%d\n", sdl.MAJOR_VERSION, sdl.MINOR_VERSION, sdl.PATCHLEVEL)
 
@@ -58,6 +26,27 @@ func main() {
 	var settingFile string
 	var sensitive float64
 	var overTcp bool
+	var decoder string
+	var recordPath string
+	var noDisplay bool
+	var apiAddr string
+	var script string
+	var serial string
+	var listDevices bool
+	var reconnectRetries int
+	var wireless bool
+	var reconnectDelay time.Duration
+	var check bool
+	var checkSize string
+	var convertPath string
+	var convertFrom string
+	var convertTo string
+	var profileDir string
+	var fullscreen bool
+	var alwaysOnTop bool
+	var borderless bool
+	var maxFingers int
+	var fingerPolicy string
 
 	flag.IntVar(&debugLevel, "log", 0, "日志等级设置")
 	flag.IntVar(&bitRate, "bitrate", 8000000, "视频码率")
@@ -65,57 +54,63 @@ func main() {
 	flag.StringVar(&settingFile, "cfg", filepath.Join(sdl.GetBasePath(), "res", "settings.yml"), "配置文件路径")
 	flag.Float64Var(&sensitive, "sens", scrcpy.DefaultMouseSensitive, "鼠标精度")
 	flag.BoolVar(&overTcp, "overtcp", false, "通过局域网连接")
+	flag.StringVar(&decoder, "decoder", scrcpy.DecoderAuto, "视频解码方式：auto、software 或硬件类型（如 videotoolbox、vaapi）")
+	flag.StringVar(&recordPath, "record", "", "录像文件路径（.mp4 或 .mkv）")
+	flag.BoolVar(&noDisplay, "no-display", false, "不显示画面，只进行控制或录像")
+	flag.StringVar(&apiAddr, "api", "", "本地控制 API 监听地址（如 127.0.0.1:27200）")
+	flag.StringVar(&script, "script", "", "启动后执行的控制脚本路径")
+	flag.StringVar(&serial, "serial", "", "设备序列号，多个设备用逗号分隔（每个设备一个窗口）")
+	flag.BoolVar(&listDevices, "list", false, "列出已连接的设备")
+	flag.BoolVar(&wireless, "wireless", false, "通过 USB 将设备切换为无线调试，之后可以拔掉 USB 线")
+	flag.IntVar(&reconnectRetries, "reconnect", 10, "断线重连次数，0 表示不重连，-1 表示不限次数")
+	flag.DurationVar(&reconnectDelay, "reconnect-delay", time.Second, "首次重连等待时间，之后逐次翻倍（最长 30s）")
+	flag.BoolVar(&check, "check", false, "只检查配置文件，列出所有错误及行号后退出")
+	flag.StringVar(&checkSize, "size", "", "配合 -check 使用，检查坐标是否超出设备画面（如 2340x1080）")
+	flag.StringVar(&convertPath, "convert", "", "将 -cfg 指定的配置文件换算为 -to 分辨率后写入该路径")
+	flag.StringVar(&convertFrom, "from", "", "配合 -convert 使用，原配置文件的分辨率（如 2248x1080），默认使用文件中的 resolution")
+	flag.StringVar(&convertTo, "to", "normalized", "配合 -convert 使用，目标分辨率（如 2340x1080）或 normalized（0~1 的比例坐标）")
+	flag.StringVar(&profileDir, "profiles", "", "配置目录，按其中 profiles.yml 的设置根据前台应用自动切换配置文件")
+	flag.BoolVar(&fullscreen, "fullscreen", false, "全屏启动（ctrl+f 切换）")
+	flag.BoolVar(&alwaysOnTop, "always-on-top", false, "窗口置顶")
+	flag.BoolVar(&borderless, "borderless", false, "无边框窗口")
+	flag.IntVar(&maxFingers, "max-fingers", scrcpy.DefaultMaxFingers, "同时按下的手指数量上限（最多 32）")
+	flag.StringVar(&fingerPolicy, "finger-policy", "reject", "手指用完时的处理方式：reject（放弃按下）、steal（抢占最早按下的宏手指）或 queue（宏、脚本及 API 等待其他手指松开）")
 	flag.Parse()
 
-	content, err := ioutil.ReadFile(settingFile)
-	if err != nil {
-		log.Fatalln(err)
-	}
-	var entryFile EntryFile
-	if err = yaml.Unmarshal(content, &entryFile); err != nil {
-		log.Fatalln(err)
+	if listDevices {
+		devices, err := scrcpy.ListDevices()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for _, d := range devices {
+			fmt.Println(d)
+		}
+		return
 	}
 
-	keyMap, ctrlKeyMap := make(map[int]scrcpy.UserOperation), make(map[int]scrcpy.UserOperation)
-	mouseKeyMap := make(map[uint8]scrcpy.UserOperation)
-
-	for _, entry := range entryFile.Entries {
-		switch entry.Type {
-		case "":
-			if keyCode, ok := scrcpy.KeyCodeConstMap[entry.Code]; ok {
-				keyMap[keyCode] = parseUserOperation(entry)
-			} else {
-				keyCode = int(sdl.GetKeyFromName(entry.Code))
-				if keyCode == sdl.K_UNKNOWN {
-					log.Fatalln("unknown key code:", entry.Code)
-				}
-				keyMap[keyCode] = parseUserOperation(entry)
-			}
-
-		case "ctrl":
-			if keyCode, ok := scrcpy.KeyCodeConstMap[entry.Code]; ok {
-				ctrlKeyMap[keyCode] = parseUserOperation(entry)
-			} else {
-				keyCode = int(sdl.GetKeyFromName(entry.Code))
-				if keyCode == sdl.K_UNKNOWN {
-					log.Fatalln("unknown key code:", entry.Code)
-				}
-				ctrlKeyMap[keyCode] = parseUserOperation(entry)
-			}
-
-		case "mouse":
-			if keyCode, ok := scrcpy.MouseButtonMap[entry.Code]; ok {
-				mouseKeyMap[keyCode] = parseUserOperation(entry)
-			} else {
-				log.Fatalln("unknown mouse code:", entry.Code)
-			}
+	if check {
+		if err := checkConfig(settingFile, checkSize); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(settingFile + ": OK")
+		return
+	}
 
-		default:
-			panic("can't reach here")
+	if len(convertPath) > 0 {
+		if err := convertConfig(settingFile, convertPath, convertFrom, convertTo); err != nil {
+			log.Fatalln(err)
 		}
+		fmt.Printf("%s -> %s (%s)\n", settingFile, convertPath, convertTo)
+		return
 	}
 
-	for _, arg := range entryFile.Args {
+	config, err := scrcpy.LoadConfig(settingFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	for _, arg := range config.Args {
 		switch arg.Name {
 		case "log":
 			debugLevel, _ = strconv.Atoi(arg.Value)
@@ -131,9 +126,61 @@ func main() {
 
 		case "overtcp":
 			overTcp = true
+
+		case "decoder":
+			decoder = arg.Value
+
+		case "record":
+			recordPath = arg.Value
+
+		case "api":
+			apiAddr = arg.Value
+
+		case "reconnect":
+			reconnectRetries, _ = strconv.Atoi(arg.Value)
+
+		case "reconnect-delay":
+			reconnectDelay, _ = time.ParseDuration(arg.Value)
+
+		case "serial":
+			if len(serial) == 0 {
+				serial = arg.Value
+			}
+
+		case "fullscreen":
+			fullscreen = true
+
+		case "always-on-top":
+			alwaysOnTop = true
+
+		case "borderless":
+			borderless = true
+
+		case "max-fingers":
+			maxFingers, _ = strconv.Atoi(arg.Value)
+
+		case "finger-policy":
+			fingerPolicy = arg.Value
+
+		case "profiles":
+			if len(profileDir) == 0 {
+				profileDir = arg.Value
+			}
+		}
+	}
+
+	var profiles *scrcpy.Profiles
+	if len(profileDir) > 0 {
+		if profiles, err = scrcpy.LoadProfiles(profileDir); err != nil {
+			log.Fatalln(err)
 		}
 	}
 
+	policy, err := scrcpy.ParseFingerPolicy(fingerPolicy)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	if overTcp && port == 27183 {
 		port = 10240
 	}
@@ -141,41 +188,171 @@ func main() {
 	option := scrcpy.Option{
 		Debug:          scrcpy.DebugLevelWrap(debugLevel),
 		BitRate:        bitRate,
+		Decoder:        decoder,
+		RecordPath:     recordPath,
+		NoDisplay:      noDisplay,
+		ApiAddr:        apiAddr,
+		Script:         script,
 		Port:           port,
-		KeyMap:         keyMap,
-		CtrlKeyMap:     ctrlKeyMap,
-		MouseKeyMap:    mouseKeyMap,
+		Config:         *config,
+		ConfigPath:     settingFile,
+		Profiles:       profiles,
 		MouseSensitive: sensitive,
 		OverTcp:        overTcp,
+
+		ReconnectRetries: reconnectRetries,
+		ReconnectDelay:   reconnectDelay,
+
+		Fullscreen:  fullscreen,
+		AlwaysOnTop: alwaysOnTop,
+		Borderless:  borderless,
+
+		MaxFingers:   maxFingers,
+		FingerPolicy: policy,
+	}
+
+	var serials []string
+	if overTcp {
+		// 局域网模式下所有设备都会连到同一个本地端口，无法区分
+		if strings.Contains(serial, ",") {
+			log.Fatalln("-overtcp supports only one device")
+		}
+		serials = []string{serial}
+	} else if serials, err = selectDevices(serial); err != nil {
+		log.Fatalln(err)
+	}
+
+	// 录像文件、API 端口和脚本都只能属于一个设备
+	if len(serials) > 1 {
+		for _, f := range []struct{ name, value string }{{"record", recordPath}, {"api", apiAddr}, {"script", script}} {
+			if len(f.value) > 0 {
+				log.Fatalf("-%s can not be used with more than one device\n", f.name)
+			}
+		}
+	}
+
+	if wireless && !overTcp {
+		for i := range serials {
+			// 已经是无线连接
+			if strings.Contains(serials[i], ":") {
+				continue
+			}
+			if serials[i], err = scrcpy.SetupWireless(serials[i], scrcpy.DefaultWirelessPort); err != nil {
+				log.Fatalln(err)
+			}
+			log.Printf("已通过无线连接 %s，可以拔掉 USB 线\n", serials[i])
+		}
+	}
+
+	var options []*scrcpy.Option
+	for _, s := range serials {
+		opt := option
+		opt.Serial = s
+		options = append(options, &opt)
+	}
+
+	log.Println(scrcpy.MainSessions(options...))
+}
+
+func checkConfig(path, size string) error {
+	config, err := scrcpy.LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	if len(size) == 0 {
+		return nil
+	}
+
+	r, err := parseResolution(size)
+	if err != nil {
+		return err
+	}
+	err = config.CheckBounds(int(r.Width), int(r.Height))
+	if ce, ok := err.(*scrcpy.ConfigError); ok {
+		ce.Path = path
 	}
+	return err
+}
 
-	for _, n := range entryFile.Hits {
-		option.Hits = append(option.Hits, time.Duration(n)*time.Millisecond)
+func convertConfig(path, output, from, to string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
 	}
 
-	for _, s := range entryFile.Stables {
-		option.Stables = append(option.Stables, &scrcpy.GunPressConfig{Delta: int32(s.Pixel), Interval: time.Duration(s.Delay) * time.Millisecond})
+	var fromRes *scrcpy.Resolution
+	if len(from) > 0 {
+		r, err := parseResolution(from)
+		if err != nil {
+			return err
+		}
+		fromRes = &r
+	}
+	toRes, err := parseResolution(to)
+	if err != nil {
+		return err
 	}
 
-	log.Println(scrcpy.Main(&option))
+	if content, err = scrcpy.ConvertConfig(content, fromRes, toRes); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(output, content, 0644)
+}
+
+func parseResolution(s string) (scrcpy.Resolution, error) {
+	if s == "normalized" {
+		return scrcpy.Normalized, nil
+	}
+	var width, height int
+	if _, err := fmt.Sscanf(s, "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+		return scrcpy.Resolution{}, fmt.Errorf("invalid resolution %q", s)
+	}
+	return scrcpy.Resolution{Width: float64(width), Height: float64(height)}, nil
 }
 
-func parseUserOperation(entry *Entry) scrcpy.UserOperation {
-	if entry.Point != nil {
-		if entry.ShowPointer {
-			return &scrcpy.SPoint{X: uint16(entry.Point.X), Y: uint16(entry.Point.Y)}
+// 未指定序列号时：只连接了一个设备则自动选择，连接了多个设备则让用户选择
+func selectDevices(serial string) ([]string, error) {
+	if len(serial) > 0 {
+		return strings.Split(serial, ","), nil
+	}
+
+	devices, err := scrcpy.ListDevices()
+	if err != nil {
+		return nil, err
+	}
+	var ready []*scrcpy.Device
+	for _, d := range devices {
+		if d.Ready() {
+			ready = append(ready, d)
 		} else {
-			return &scrcpy.Point{uint16(entry.Point.X), uint16(entry.Point.Y)}
+			log.Printf("ignore device %s (%s)\n", d.Serial, d.State)
 		}
-	} else if len(entry.Macro) > 0 {
-		var list []*scrcpy.PointMacro
-		for _, m := range entry.Macro {
-			list = append(list, &scrcpy.PointMacro{
-				Point:    scrcpy.Point{X: uint16(m.Point.X), Y: uint16(m.Point.Y)},
-				Interval: time.Duration(m.Delay) * time.Millisecond})
+	}
+
+	switch len(ready) {
+	case 0:
+		return nil, errors.New("no device attached")
+
+	case 1:
+		return []string{ready[0].Serial}, nil
+	}
+
+	for i, d := range ready {
+		fmt.Printf("%d) %s\n", i+1, d)
+	}
+	fmt.Print("选择设备（多个用逗号分隔）：")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return nil, errors.New("more than one device attached, use -serial to choose")
+	}
+
+	var serials []string
+	for _, s := range strings.Split(strings.TrimSpace(line), ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n < 1 || n > len(ready) {
+			return nil, fmt.Errorf("invalid choice: %s", s)
 		}
-		return list
-	} else {
-		panic("can't reach here")
+		serials = append(serials, ready[n-1].Serial)
 	}
+	return serials, nil
 }
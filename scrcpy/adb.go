@@ -43,6 +43,22 @@ func adbForwardRemove(serial string, localPort int) error {
 		fmt.Sprintf("tcp:%d", localPort))
 }
 
+func adbTcpip(serial string, port int) error {
+	return adbExec(serial, "tcpip", fmt.Sprintf("%d", port))
+}
+
+func adbConnect(addr string) error {
+	out, err := adbOutput("", "connect", addr)
+	if err != nil {
+		return err
+	}
+	// 连接失败时 adb 的返回值仍然可能是 0
+	if !strings.Contains(string(out), "connected to") {
+		return fmt.Errorf("adb connect %s: %s", addr, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 func adbExec(serial string, params ...string) error {
 	if cmd, err := adbExecAsync(serial, params...); err != nil {
 		return err
@@ -52,6 +68,28 @@ func adbExec(serial string, params ...string) error {
 }
 
 func adbExecAsync(serial string, params ...string) (*exec.Cmd, error) {
+	cmd := adbCommand(serial, params...)
+	if debugOpt.Debug() {
+		cmd.Stderr = os.Stderr
+		cmd.Stdout = os.Stdout
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// 执行 adb 命令并返回标准输出
+func adbOutput(serial string, params ...string) ([]byte, error) {
+	cmd := adbCommand(serial, params...)
+	if debugOpt.Debug() {
+		cmd.Stderr = os.Stderr
+	}
+	return cmd.Output()
+}
+
+func adbCommand(serial string, params ...string) *exec.Cmd {
 	args := make([]string, 0, 8)
 	if len(serial) > 0 {
 		args = append(args, "-s", serial)
@@ -62,16 +100,72 @@ func adbExecAsync(serial string, params ...string) (*exec.Cmd, error) {
 	if debugOpt.Debug() {
 		log.Printf("执行 %s %s\n", adbCmd, strings.Join(args, " "))
 	}
-	cmd := exec.Command(adbCmd, args...)
-	if debugOpt.Debug() {
-		cmd.Stderr = os.Stderr
-		cmd.Stdout = os.Stdout
-	}
+	return exec.Command(adbCmd, args...)
+}
 
-	if err := cmd.Start(); err != nil {
+// adb devices -l 列出的设备
+type Device struct {
+	Serial string
+	// device、offline、unauthorized 等
+	State string
+	Model string
+	// usb、tcp 或 emulator
+	Transport string
+}
+
+func (d *Device) Ready() bool {
+	return d.State == "device"
+}
+
+func (d *Device) String() string {
+	return fmt.Sprintf("%-24s %-13s %-9s %s", d.Serial, d.State, d.Transport, d.Model)
+}
+
+func ListDevices() ([]*Device, error) {
+	out, err := adbOutput("", "devices", "-l")
+	if err != nil {
 		return nil, err
 	}
-	return cmd, nil
+	return parseDevices(string(out)), nil
+}
+
+// 解析如下格式的输出：
+//
+//	List of devices attached
+//	0123456789ABCDEF       device usb:1-1 product:x model:Pixel_3 device:blueline transport_id:1
+//	192.168.1.5:5555       device product:x model:MI_8 device:dipper transport_id:2
+func parseDevices(out string) []*Device {
+	var devices []*Device
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(line, "List of devices") || strings.HasPrefix(line, "*") {
+			continue
+		}
+
+		d := Device{Serial: fields[0], State: fields[1]}
+		// Linux 上没有 USB 权限时为 no permissions (原因); see [链接]
+		if d.State == "no" && len(fields) > 2 && fields[2] == "permissions" {
+			d.State = "no permissions"
+		}
+		for _, f := range fields[2:] {
+			if strings.HasPrefix(f, "model:") {
+				d.Model = strings.Replace(f[len("model:"):], "_", " ", -1)
+			} else if strings.HasPrefix(f, "usb:") {
+				d.Transport = "usb"
+			}
+		}
+		if len(d.Transport) == 0 {
+			if strings.HasPrefix(d.Serial, "emulator-") {
+				d.Transport = "emulator"
+			} else if strings.Contains(d.Serial, ":") {
+				d.Transport = "tcp"
+			} else {
+				d.Transport = "usb"
+			}
+		}
+		devices = append(devices, &d)
+	}
+	return devices
 }
 
 var adbCmd = "adb"
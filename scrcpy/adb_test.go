@@ -0,0 +1,51 @@
+package scrcpy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDevices(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []Device
+	}{
+		{"devices", `* daemon not running; starting now at tcp:5037
+* daemon started successfully
+List of devices attached
+0123456789ABCDEF       device usb:1-1 product:blueline model:Pixel_3 device:blueline transport_id:1
+192.168.1.5:5555       device product:dipper model:MI_8 device:dipper transport_id:2
+emulator-5554          offline transport_id:3
+R58M123ABC             unauthorized usb:1-2 transport_id:4
+
+`, []Device{
+			{Serial: "0123456789ABCDEF", State: "device", Model: "Pixel 3", Transport: "usb"},
+			{Serial: "192.168.1.5:5555", State: "device", Model: "MI 8", Transport: "tcp"},
+			{Serial: "emulator-5554", State: "offline", Transport: "emulator"},
+			{Serial: "R58M123ABC", State: "unauthorized", Transport: "usb"},
+		}},
+		// 旧版本的 adb 没有 usb: 等字段，Windows 上以 \r\n 换行
+		{"old adb", "List of devices attached\r\n0123456789ABCDEF\tdevice\r\n\r\n", []Device{
+			{Serial: "0123456789ABCDEF", State: "device", Transport: "usb"},
+		}},
+		{"no permissions", `List of devices attached
+0123456789ABCDEF       no permissions (user in plugdev group; are your udev rules wrong?); see [http://developer.android.com/tools/device.html] usb:1-1 transport_id:1
+`, []Device{
+			{Serial: "0123456789ABCDEF", State: "no permissions", Transport: "usb"},
+		}},
+		{"no devices", "List of devices attached\n\n", nil},
+		{"empty", "", nil},
+		{"unexpected", "garbage\n", nil},
+	}
+
+	for _, tt := range tests {
+		var got []Device
+		for _, d := range parseDevices(tt.out) {
+			got = append(got, *d)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package scrcpy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 脚本及本地 API 使用的文本命令，每行一条：
+//
+//	tap X Y
+//	down ID X Y
+//	move ID X Y
+//	up ID X Y
+//	swipe X1 Y1 X2 Y2 [毫秒]
+//	key NAME|KEYCODE
+//	keydown NAME|KEYCODE
+//	keyup NAME|KEYCODE
+//	text 任意文字（一直到行尾）
+//	sleep 毫秒
+//
+// 其中 ID 是调用方自定义的手指名称，坐标为设备视频帧坐标。
+// 以 # 开头的行为注释。
+
+var errQuit = errors.New("quit")
+
+var errNoFinger = errors.New("all fingers are in use")
+
+var androidKeyNames = map[string]int{
+	"HOME":        AKEYCODE_HOME,
+	"BACK":        AKEYCODE_BACK,
+	"MENU":        AKEYCODE_MENU,
+	"APP_SWITCH":  AKEYCODE_APP_SWITCH,
+	"POWER":       AKEYCODE_POWER,
+	"VOLUME_UP":   AKEYCODE_VOLUME_UP,
+	"VOLUME_DOWN": AKEYCODE_VOLUME_DOWN,
+	"ENTER":       AKEYCODE_ENTER,
+	"DEL":         AKEYCODE_DEL,
+	"FORWARD_DEL": AKEYCODE_FORWARD_DEL,
+	"TAB":         AKEYCODE_TAB,
+	"SPACE":       AKEYCODE_SPACE,
+	"ESCAPE":      AKEYCODE_ESCAPE,
+	"UP":          AKEYCODE_DPAD_UP,
+	"DOWN":        AKEYCODE_DPAD_DOWN,
+	"LEFT":        AKEYCODE_DPAD_LEFT,
+	"RIGHT":       AKEYCODE_DPAD_RIGHT,
+	"CENTER":      AKEYCODE_DPAD_CENTER,
+}
+
+// 执行文本命令，可以同时服务多个 API 连接
+type commandRunner struct {
+	controller Controller
+	fingers    *fingerState
+	pointers   map[string]*int
+	// 只保护 pointers，tap、swipe 等待期间不持有，以免阻塞其他连接及重连时的 reset
+	mutex sync.Mutex
+}
+
+func newCommandRunner(controller Controller, fingers *fingerState) *commandRunner {
+	return &commandRunner{controller: controller, fingers: fingers, pointers: make(map[string]*int)}
+}
+
+func parsePoint(args []string) (p Point, err error) {
+	if len(args) < 2 {
+		return p, errors.New("missing coordinates")
+	}
+	var x, y uint64
+	if x, err = strconv.ParseUint(args[0], 10, 16); err != nil {
+		return
+	}
+	if y, err = strconv.ParseUint(args[1], 10, 16); err != nil {
+		return
+	}
+	return Point{uint16(x), uint16(y)}, nil
+}
+
+func parseAndroidKey(name string) (int, error) {
+	if keyCode, ok := androidKeyNames[strings.ToUpper(name)]; ok {
+		return keyCode, nil
+	}
+	keyCode, err := strconv.Atoi(name)
+	if err != nil {
+		return 0, fmt.Errorf("unknown key: %s", name)
+	}
+	return keyCode, nil
+}
+
+func (cr *commandRunner) run(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return nil
+	}
+
+	cmd, args := strings.ToLower(fields[0]), fields[1:]
+	switch cmd {
+	case "quit":
+		return errQuit
+
+	case "sleep":
+		if len(args) < 1 {
+			return errors.New("missing duration")
+		}
+		ms, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+		time.Sleep(time.Duration(ms) * time.Millisecond)
+		return nil
+	}
+
+	switch cmd {
+	case "tap":
+		p, err := parsePoint(args)
+		if err != nil {
+			return err
+		}
+		id := cr.fingers.waitId()
+		if id == nil {
+			return errNoFinger
+		}
+		defer cr.fingers.Recycle(id)
+		if err = cr.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *id, p); err != nil {
+			return err
+		}
+		time.Sleep(30 * time.Millisecond)
+		return cr.sendMouseEvent(AMOTION_EVENT_ACTION_UP, *id, p)
+
+	case "down", "move", "up":
+		if len(args) < 1 {
+			return errors.New("missing pointer id")
+		}
+		p, err := parsePoint(args[1:])
+		if err != nil {
+			return err
+		}
+		return cr.pointer(cmd, args[0], p)
+
+	case "swipe":
+		if len(args) < 4 {
+			return errors.New("missing coordinates")
+		}
+		from, err := parsePoint(args[0:2])
+		if err != nil {
+			return err
+		}
+		to, err := parsePoint(args[2:4])
+		if err != nil {
+			return err
+		}
+		duration := 300 * time.Millisecond
+		if len(args) > 4 {
+			ms, err := strconv.Atoi(args[4])
+			if err != nil {
+				return err
+			}
+			duration = time.Duration(ms) * time.Millisecond
+		}
+		return cr.swipe(from, to, duration)
+
+	case "text":
+		text := strings.TrimSpace(line)[len(fields[0]):]
+		return pushText(cr.controller, strings.TrimPrefix(text, " "))
+
+	case "key", "keydown", "keyup":
+		if len(args) < 1 {
+			return errors.New("missing key")
+		}
+		keyCode, err := parseAndroidKey(args[0])
+		if err != nil {
+			return err
+		}
+		if cmd != "keyup" {
+			if err = cr.controller.PushEvent(&keyCodeEvent{action: AKEY_EVENT_ACTION_DOWN, keyCode: keyCode}); err != nil {
+				return err
+			}
+		}
+		if cmd != "keydown" {
+			return cr.controller.PushEvent(&keyCodeEvent{action: AKEY_EVENT_ACTION_UP, keyCode: keyCode})
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown command: %s", cmd)
+}
+
+func (cr *commandRunner) pointer(cmd, name string, p Point) error {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	id := cr.pointers[name]
+	switch cmd {
+	case "down":
+		if id != nil {
+			return fmt.Errorf("pointer %s already down", name)
+		}
+		// FingerQueue 时可能等待其他手指松开，等待期间不持有锁
+		cr.mutex.Unlock()
+		id = cr.fingers.waitId()
+		cr.mutex.Lock()
+		if id == nil {
+			return errNoFinger
+		}
+		if cr.pointers[name] != nil {
+			cr.fingers.Recycle(id)
+			return fmt.Errorf("pointer %s already down", name)
+		}
+		cr.pointers[name] = id
+		return cr.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *id, p)
+
+	case "move":
+		if id == nil {
+			return fmt.Errorf("pointer %s is not down", name)
+		}
+		return cr.sendMouseEvent(AMOTION_EVENT_ACTION_MOVE, *id, p)
+
+	default:
+		if id == nil {
+			return fmt.Errorf("pointer %s is not down", name)
+		}
+		delete(cr.pointers, name)
+		defer cr.fingers.Recycle(id)
+		return cr.sendMouseEvent(AMOTION_EVENT_ACTION_UP, *id, p)
+	}
+}
+
+func (cr *commandRunner) swipe(from, to Point, duration time.Duration) error {
+	const step = 16 * time.Millisecond
+	id := cr.fingers.waitId()
+	if id == nil {
+		return errNoFinger
+	}
+	defer cr.fingers.Recycle(id)
+
+	if err := cr.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *id, from); err != nil {
+		return err
+	}
+	n := int(duration / step)
+	for i := 1; i <= n; i++ {
+		time.Sleep(step)
+		p := Point{
+			X: uint16(int(from.X) + (int(to.X)-int(from.X))*i/n),
+			Y: uint16(int(from.Y) + (int(to.Y)-int(from.Y))*i/n),
+		}
+		if err := cr.sendMouseEvent(AMOTION_EVENT_ACTION_MOVE, *id, p); err != nil {
+			return err
+		}
+	}
+	return cr.sendMouseEvent(AMOTION_EVENT_ACTION_UP, *id, to)
+}
+
+func (cr *commandRunner) sendMouseEvent(action androidMotionEventAction, id int, p Point) error {
+	sme := singleMouseEvent{action: action}
+	sme.id = id
+	sme.Point = p
+	return cr.controller.PushEvent(&sme)
+}
+
+// 松开所有仍处于按下状态的手指
+func (cr *commandRunner) releaseAll() {
+	cr.mutex.Lock()
+	names := make([]string, 0, len(cr.pointers))
+	for name := range cr.pointers {
+		names = append(names, name)
+	}
+	cr.mutex.Unlock()
+
+	for _, name := range names {
+		cr.pointer("up", name, Point{})
+	}
+}
+
+// 重连后设备上已经没有按下的手指，只清空记录
+func (cr *commandRunner) reset() {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	cr.pointers = make(map[string]*int)
+}
+
+// 逐行执行命令，出错时返回错误所在行号
+func (cr *commandRunner) runScript(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if err := cr.run(scanner.Text()); err == errQuit {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func (cr *commandRunner) runScriptFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return cr.runScript(f)
+}
+
+// 本地 API：每个连接逐行发送命令，每条命令回复一行 ok 或者 error: 原因
+type apiServer struct {
+	runner   *commandRunner
+	listener net.Listener
+}
+
+func startApiServer(addr string, runner *commandRunner) (*apiServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if debugOpt.Info() {
+		log.Println("API listening on", listener.Addr())
+	}
+	api := &apiServer{runner: runner, listener: listener}
+	go api.serve()
+	return api, nil
+}
+
+func (api *apiServer) serve() {
+	for {
+		conn, err := api.listener.Accept()
+		if err != nil {
+			return
+		}
+		go api.handle(conn)
+	}
+}
+
+func (api *apiServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		err := api.runner.run(scanner.Text())
+		if err == errQuit {
+			return
+		} else if err != nil {
+			fmt.Fprintf(conn, "error: %v\n", err)
+		} else {
+			fmt.Fprintln(conn, "ok")
+		}
+	}
+}
+
+func (api *apiServer) Close() error {
+	return api.listener.Close()
+}
@@ -0,0 +1,121 @@
+package scrcpy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 把推送的事件写成便于比较的文字
+func describeEvents(events []interface{}) []string {
+	var list []string
+	for _, e := range events {
+		switch ev := e.(type) {
+		case *singleMouseEvent:
+			action := map[androidMotionEventAction]string{
+				AMOTION_EVENT_ACTION_DOWN: "down",
+				AMOTION_EVENT_ACTION_MOVE: "move",
+				AMOTION_EVENT_ACTION_UP:   "up",
+			}[ev.action]
+			list = append(list, fmt.Sprintf("%s %d %d,%d", action, ev.id, ev.X, ev.Y))
+		case *keyCodeEvent:
+			action := "keydown"
+			if ev.action == AKEY_EVENT_ACTION_UP {
+				action = "keyup"
+			}
+			list = append(list, fmt.Sprintf("%s %d", action, ev.keyCode))
+		case *textEvent:
+			list = append(list, "text "+ev.text)
+		default:
+			list = append(list, fmt.Sprintf("%T", e))
+		}
+	}
+	return list
+}
+
+func TestCommandRunnerRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		lines  []string
+		err    string // 最后一行的错误，为空时所有命令都应成功
+		events []string
+	}{
+		{"comment", []string{"", "  # tap 1 1"}, "", nil},
+		{"tap", []string{"tap 10 20"}, "", []string{"down 0 10,20", "up 0 10,20"}},
+		{"pointer", []string{"down a 1 2", "down b 3 4", "move a 5 6", "up a 7 8", "up b 9 10"}, "",
+			[]string{"down 0 1,2", "down 1 3,4", "move 0 5,6", "up 0 7,8", "up 1 9,10"}},
+		{"swipe", []string{"swipe 0 0 32 64 32"}, "",
+			[]string{"down 0 0,0", "move 0 16,32", "move 0 32,64", "up 0 32,64"}},
+		{"text", []string{"text  hello world "}, "", []string{"text  hello world"}},
+		{"key", []string{"key home", "keydown 4", "keyup BACK"}, "",
+			[]string{"keydown 3", "keyup 3", "keydown 4", "keyup 4"}},
+
+		{"unknown command", []string{"press 1 1"}, "unknown command", nil},
+		{"missing coordinates", []string{"tap 1"}, "missing coordinates", nil},
+		{"invalid coordinate", []string{"tap x 1"}, "invalid syntax", nil},
+		{"coordinate out of range", []string{"tap 70000 1"}, "out of range", nil},
+		{"missing pointer id", []string{"down"}, "missing pointer id", nil},
+		{"move before down", []string{"move a 1 1"}, "not down", nil},
+		{"up twice", []string{"down a 1 1", "up a 1 1", "up a 1 1"}, "not down",
+			[]string{"down 0 1,1", "up 0 1,1"}},
+		{"down twice", []string{"down a 1 1", "down a 2 2"}, "already down", []string{"down 0 1,1"}},
+		{"no finger", []string{"down a 1 1", "down b 2 2"}, errNoFinger.Error(), []string{"down 0 1,1"}},
+		{"swipe missing coordinates", []string{"swipe 1 2 3"}, "missing coordinates", nil},
+		{"swipe invalid duration", []string{"swipe 1 2 3 4 x"}, "invalid syntax", nil},
+		{"missing key", []string{"key"}, "missing key", nil},
+		{"unknown key", []string{"key NOPE"}, "unknown key", nil},
+		{"missing duration", []string{"sleep"}, "missing duration", nil},
+		{"quit", []string{"quit"}, errQuit.Error(), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fingers fingerState
+			limit := 0
+			if tt.name == "no finger" {
+				limit = 1
+			}
+			fingers.configure(limit, FingerReject)
+			rc := &recordController{}
+			cr := newCommandRunner(rc, &fingers)
+
+			for i, line := range tt.lines {
+				err := cr.run(line)
+				if i < len(tt.lines)-1 || tt.err == "" {
+					if err != nil {
+						t.Fatalf("%q: %v", line, err)
+					}
+				} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("%q: err = %v, want %q", line, err, tt.err)
+				}
+			}
+			if got := describeEvents(rc.events); strings.Join(got, "; ") != strings.Join(tt.events, "; ") {
+				t.Errorf("events = %q, want %q", got, tt.events)
+			}
+		})
+	}
+}
+
+// swipe 进行期间，其他连接的命令以及重连时的 reset 不需要等待
+func TestCommandRunnerSwipeDoesNotBlock(t *testing.T) {
+	var fingers fingerState
+	rc := &recordController{}
+	cr := newCommandRunner(rc, &fingers)
+
+	done := make(chan error)
+	go func() { done <- cr.run("swipe 0 0 100 100 1000") }()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	if err := cr.run("down a 1 1"); err != nil {
+		t.Fatal(err)
+	}
+	cr.reset()
+	if d := time.Since(start); d > 200*time.Millisecond {
+		t.Errorf("blocked %v behind the swipe", d)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
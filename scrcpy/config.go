@@ -0,0 +1,784 @@
+package scrcpy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ClarkGuan/go-sdl2/sdl"
+	"gopkg.in/yaml.v3"
+)
+
+type EntryFile struct {
+	Entries []*Entry  `yaml:"keys"`
+	Args    []*Arg    `yaml:"args"`
+	Hits    []int     `yaml:"hits"`
+	Stables []*Stable `yaml:"stable"`
+}
+
+type Stable struct {
+	Pixel int `yaml:"pixel"`
+	Delay int `yaml:"delay"`
+}
+
+type Arg struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+type Entry struct {
+	Code        string        `yaml:"code"`
+	Point       *EntryPoint   `yaml:"point"`
+	Comment     string        `yaml:"comment"`
+	Macro       []*EntryMacro `yaml:"macro"`
+	ShowPointer bool          `yaml:"show_pointer"`
+	Type        string        `yaml:"type"`
+	Wheel       *EntryWheel   `yaml:"wheel"`
+}
+
+// 坐标可以是设备像素，也可以是相对于 resolution 的坐标（resolution 为 1x1 时即 0~1 的比例坐标）
+type EntryPoint struct {
+	X float64 `yaml:"x"`
+	Y float64 `yaml:"y"`
+}
+
+// 配置文件中坐标所对应的参考分辨率
+type Resolution struct {
+	Width  float64 `yaml:"width"`
+	Height float64 `yaml:"height"`
+}
+
+// 比例坐标
+var Normalized = Resolution{1, 1}
+
+func (r Resolution) String() string {
+	if r == Normalized {
+		return "normalized"
+	}
+	return fmt.Sprintf("%gx%g", r.Width, r.Height)
+}
+
+// 设备屏幕方向，由视频帧的宽高判断
+type Orientation int
+
+const (
+	Portrait Orientation = iota
+	Landscape
+)
+
+var orientationNames = map[string]Orientation{
+	"portrait":  Portrait,
+	"landscape": Landscape,
+}
+
+func orientationOf(width, height int) Orientation {
+	if width > height {
+		return Landscape
+	}
+	return Portrait
+}
+
+func (o Orientation) String() string {
+	if o == Landscape {
+		return "landscape"
+	}
+	return "portrait"
+}
+
+// 交换宽高使其与 o 的方向一致，宽高相同时不变
+func (r Resolution) oriented(o Orientation) Resolution {
+	if r.Width != r.Height && (r.Width > r.Height) != (o == Landscape) {
+		return Resolution{Width: r.Height, Height: r.Width}
+	}
+	return r
+}
+
+// 手柄摇杆的设置：左摇杆控制方向，右摇杆控制视角
+type GamepadConfig struct {
+	// 摇杆偏移小于该比例（0~1）时视为没有推动
+	DeadZone float64 `yaml:"dead_zone"`
+	// 去掉死区后的偏移按该指数换算，大于 1 时小幅推动更精细
+	Curve float64 `yaml:"curve"`
+	// 右摇杆推到底时视角每秒移动的像素
+	Speed float64 `yaml:"speed"`
+}
+
+var DefaultGamepadConfig = GamepadConfig{DeadZone: 0.15, Curve: 2, Speed: 1200}
+
+type EntryMacro struct {
+	Point *EntryPoint `yaml:"point"`
+	Delay int         `yaml:"delay"`
+}
+
+// 配置文件中与按键映射相关的部分，可以在运行中重新加载
+type Config struct {
+	KeyMap      map[int]UserOperation
+	CtrlKeyMap  map[int]UserOperation
+	MouseKeyMap map[uint8]UserOperation
+	// 手柄按键及滚轮拖动，见 GamepadButtonMap、WheelCodeMap
+	GamepadKeyMap map[int]UserOperation
+	WheelKeyMap   map[int]UserOperation
+	Hits          []time.Duration
+	Stables       []*GunPressConfig
+	Gamepad       GamepadConfig
+	// 命令行参数的默认值，只在启动时由 main 读取
+	Args []*Arg
+	// 为空时坐标即设备像素，否则需要通过 ScaleTo 换算
+	Resolution *Resolution
+	// 配置文件中 portrait、landscape 段的按键映射，设备处于对应方向时代替 keys
+	Orientations map[Orientation]*Config
+
+	// 所有坐标及其所在行，用于检查是否超出设备画面以及按分辨率换算
+	points []configPoint
+}
+
+type configPoint struct {
+	line int
+	code string
+	x, y float64
+	p    *Point
+}
+
+// 配置文件中的一处错误
+type ConfigProblem struct {
+	Line    int
+	Message string
+}
+
+// 配置文件的所有错误，每行一个
+type ConfigError struct {
+	Path     string
+	Problems []ConfigProblem
+}
+
+func (e *ConfigError) Error() string {
+	var b strings.Builder
+	for i, p := range e.Problems {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		if len(e.Path) > 0 {
+			fmt.Fprintf(&b, "%s:", e.Path)
+		}
+		fmt.Fprintf(&b, "%d: %s", p.Line, p.Message)
+	}
+	return b.String()
+}
+
+// 必须配置的坐标，缺少时视角及方向控制无法工作
+var requiredKeyCodes = []string{
+	SCRCPY_FIRE,
+	SCRCPY_VISION_TOPLEFT,
+	SCRCPY_VISION_BOTTOMRIGHT,
+	SCRCPY_FRONT,
+	SCRCPY_BACK,
+}
+
+var entryFields = map[string]bool{
+	"code":         true,
+	"point":        true,
+	"comment":      true,
+	"macro":        true,
+	"show_pointer": true,
+	"type":         true,
+	"wheel":        true,
+}
+
+func LoadConfig(path string) (*Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ParseConfig(content)
+	if ce, ok := err.(*ConfigError); ok {
+		ce.Path = path
+	}
+	return cfg, err
+}
+
+// 解析并检查整个配置文件，返回的 *ConfigError 包含所有问题及其行号
+func ParseConfig(content []byte) (*Config, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+
+	p := newConfigParser()
+	p.parse(&doc)
+
+	if len(p.problems) > 0 {
+		return nil, &ConfigError{Problems: p.problems}
+	}
+	return p.cfg, nil
+}
+
+type configParser struct {
+	cfg      *Config
+	problems []ConfigProblem
+	// 已经绑定的按键及其所在行
+	bindings map[string]int
+}
+
+func newConfigParser() *configParser {
+	return &configParser{cfg: &Config{
+		KeyMap:        make(map[int]UserOperation),
+		CtrlKeyMap:    make(map[int]UserOperation),
+		MouseKeyMap:   make(map[uint8]UserOperation),
+		GamepadKeyMap: make(map[int]UserOperation),
+		WheelKeyMap:   make(map[int]UserOperation),
+		Gamepad:       DefaultGamepadConfig,
+	}, bindings: make(map[string]int)}
+}
+
+func (p *configParser) errorf(line int, format string, args ...interface{}) {
+	p.problems = append(p.problems, ConfigProblem{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// yaml 的类型错误中已经包含了行号
+func (p *configParser) decode(node *yaml.Node, v interface{}) bool {
+	if err := node.Decode(v); err != nil {
+		if te, ok := err.(*yaml.TypeError); ok {
+			for _, e := range te.Errors {
+				line := node.Line
+				if n, err := fmt.Sscanf(e, "line %d: ", &line); n == 1 && err == nil {
+					e = e[strings.Index(e, ": ")+2:]
+				}
+				p.problems = append(p.problems, ConfigProblem{Line: line, Message: e})
+			}
+		} else {
+			p.errorf(node.Line, "%v", err)
+		}
+		return false
+	}
+	return true
+}
+
+func (p *configParser) parse(doc *yaml.Node) {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		p.errorf(1, "empty config")
+		return
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		p.errorf(root.Line, "config must be a mapping")
+		return
+	}
+
+	p.parseFields(root, "")
+
+	// 方向段共用连击及压枪配置，没有单独设置 resolution 时使用旋转后的 resolution
+	for o, section := range p.cfg.Orientations {
+		section.Hits = p.cfg.Hits
+		section.Stables = p.cfg.Stables
+		section.Gamepad = p.cfg.Gamepad
+		if section.Resolution == nil && p.cfg.Resolution != nil {
+			r := p.cfg.Resolution.oriented(o)
+			section.Resolution = &r
+		}
+	}
+}
+
+// 解析顶层或者方向段（section 为段名）中的字段，方向段中只能包含 keys 和 resolution
+func (p *configParser) parseFields(node *yaml.Node, section string) {
+	keysLine := node.Line
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if len(section) > 0 && key.Value != "keys" && key.Value != "resolution" {
+			p.errorf(key.Line, "unknown field %q in %s", key.Value, section)
+			continue
+		}
+
+		switch key.Value {
+		case "keys":
+			keysLine = key.Line
+			if value.Kind != yaml.SequenceNode {
+				p.errorf(value.Line, "keys must be a list")
+				continue
+			}
+			for _, item := range value.Content {
+				p.parseEntry(item)
+			}
+
+		case "args":
+			p.decode(value, &p.cfg.Args)
+
+		case "resolution":
+			var r Resolution
+			if !p.decode(value, &r) {
+				continue
+			}
+			if r.Width <= 0 || r.Height <= 0 {
+				p.errorf(value.Line, "invalid resolution %v", r)
+				continue
+			}
+			p.cfg.Resolution = &r
+
+		case "hits":
+			var hits []int
+			if p.decode(value, &hits) {
+				for _, n := range hits {
+					p.cfg.Hits = append(p.cfg.Hits, time.Duration(n)*time.Millisecond)
+				}
+			}
+
+		case "stable":
+			var stables []*Stable
+			if p.decode(value, &stables) {
+				for _, s := range stables {
+					p.cfg.Stables = append(p.cfg.Stables, &GunPressConfig{Delta: int32(s.Pixel), Interval: time.Duration(s.Delay) * time.Millisecond})
+				}
+			}
+
+		case "gamepad":
+			gc := DefaultGamepadConfig
+			if !p.decode(value, &gc) {
+				continue
+			}
+			if gc.DeadZone < 0 || gc.DeadZone >= 1 || gc.Curve <= 0 || gc.Speed <= 0 {
+				p.errorf(value.Line, "invalid gamepad settings: dead_zone must be in [0, 1), curve and speed must be positive")
+				continue
+			}
+			p.cfg.Gamepad = gc
+
+		case "portrait", "landscape":
+			p.parseSection(key, value)
+
+		default:
+			p.errorf(key.Line, "unknown field %q", key.Value)
+		}
+	}
+
+	for _, code := range requiredKeyCodes {
+		if _, ok := p.cfg.KeyMap[KeyCodeConstMap[code]].(*Point); !ok {
+			if len(section) > 0 {
+				p.errorf(keysLine, "missing point of %s in %s", code, section)
+			} else {
+				p.errorf(keysLine, "missing point of %s", code)
+			}
+		}
+	}
+}
+
+// 方向段是一份独立的按键映射，其中的按键可以与 keys 中的重复
+func (p *configParser) parseSection(key, value *yaml.Node) {
+	if value.Kind != yaml.MappingNode {
+		p.errorf(value.Line, "%s must be a mapping", key.Value)
+		return
+	}
+	sp := newConfigParser()
+	sp.parseFields(value, key.Value)
+	p.problems = append(p.problems, sp.problems...)
+
+	if p.cfg.Orientations == nil {
+		p.cfg.Orientations = make(map[Orientation]*Config)
+	}
+	p.cfg.Orientations[orientationNames[key.Value]] = sp.cfg
+}
+
+func (p *configParser) parseEntry(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		p.errorf(node.Line, "key entry must be a mapping")
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if key := node.Content[i]; !entryFields[key.Value] {
+			p.errorf(key.Line, "unknown field %q", key.Value)
+		}
+	}
+
+	var entry Entry
+	if !p.decode(node, &entry) {
+		return
+	}
+
+	if entry.Type == "wheel" {
+		p.parseWheel(node, &entry)
+		return
+	} else if entry.Wheel != nil {
+		p.errorf(fieldLine(node, "wheel"), "wheel settings of %s need type: wheel", entry.Code)
+	}
+
+	opr := p.parseUserOperation(node, &entry)
+	if opr == nil {
+		return
+	}
+
+	switch entry.Type {
+	case "":
+		if keyCode, ok := p.parseKeyCode(node.Line, &entry); ok && p.bind(node.Line, &entry, keyCode) {
+			p.cfg.KeyMap[keyCode] = opr
+		}
+
+	case "ctrl":
+		keyCode, ok := p.parseKeyCode(node.Line, &entry)
+		if ok && ctrlReservedKeys[sdl.Keycode(keyCode)] {
+			p.errorf(node.Line, "ctrl+%s is reserved for a built-in shortcut", entry.Code)
+		} else if ok && p.bind(node.Line, &entry, keyCode) {
+			p.cfg.CtrlKeyMap[keyCode] = opr
+		}
+
+	case "gamepad":
+		if button, ok := GamepadButtonMap[entry.Code]; !ok {
+			p.errorf(node.Line, "unknown gamepad code: %s", entry.Code)
+		} else if p.bind(node.Line, &entry, button) {
+			p.cfg.GamepadKeyMap[button] = opr
+		}
+
+	case "mouse":
+		if keyCode, ok := MouseButtonMap[entry.Code]; !ok {
+			p.errorf(node.Line, "unknown mouse code: %s", entry.Code)
+		} else if p.bind(node.Line, &entry, int(keyCode)) {
+			p.cfg.MouseKeyMap[keyCode] = opr
+		}
+
+	default:
+		p.errorf(node.Line, "unknown type %q of %s", entry.Type, entry.Code)
+	}
+}
+
+// 滚轮拖动只能从一个点开始，其余设置都有默认值
+func (p *configParser) parseWheel(node *yaml.Node, entry *Entry) {
+	code, ok := WheelCodeMap[entry.Code]
+	if !ok {
+		p.errorf(node.Line, "unknown wheel code: %s", entry.Code)
+		return
+	}
+	if entry.Point == nil || len(entry.Macro) > 0 || entry.ShowPointer {
+		p.errorf(node.Line, "wheel %s must be a point without macro or show_pointer", entry.Code)
+		return
+	}
+
+	wd := &WheelDrag{Max: -1, Delay: defaultWheelDelay}
+	if w := entry.Wheel; w != nil {
+		line := fieldLine(node, "wheel")
+		switch w.Axis {
+		case "", "y":
+		case "x":
+			wd.Horizontal = true
+		default:
+			p.errorf(line, "invalid wheel axis %q of %s (x or y)", w.Axis, entry.Code)
+			return
+		}
+		wd.Step = w.Step
+		if w.Min < 0 || (w.Max != nil && *w.Max < w.Min) {
+			p.errorf(line, "invalid wheel range of %s: min must not be negative or greater than max", entry.Code)
+			return
+		}
+		wd.Min = w.Min
+		if w.Max != nil {
+			wd.Max = *w.Max
+		}
+		if w.Delay != nil {
+			if *w.Delay <= 0 {
+				p.errorf(line, "wheel delay of %s must be positive", entry.Code)
+				return
+			}
+			wd.Delay = time.Duration(*w.Delay) * time.Millisecond
+		}
+	}
+
+	if p.bind(node.Line, entry, code) {
+		p.addPoint(fieldLine(node, "point"), entry.Code, entry.Point, &wd.Point)
+		p.cfg.WheelKeyMap[code] = wd
+	}
+}
+
+func (p *configParser) parseKeyCode(line int, entry *Entry) (int, bool) {
+	if len(entry.Code) == 0 {
+		p.errorf(line, "missing code")
+		return 0, false
+	}
+	keyCode, err := parseKeyCode(entry.Code)
+	if err != nil {
+		p.errorf(line, "%v", err)
+		return 0, false
+	}
+	return keyCode, true
+}
+
+// 程序自身的 ctrl 快捷键（见 README 的特殊功能）先于按键映射处理，不能再绑定为 type: ctrl
+var ctrlReservedKeys = map[sdl.Keycode]bool{
+	sdl.K_h: true, sdl.K_b: true, sdl.K_m: true, sdl.K_p: true, sdl.K_s: true,
+	sdl.K_SEMICOLON: true, sdl.K_QUOTE: true, sdl.K_x: true, sdl.K_v: true, sdl.K_e: true,
+	sdl.K_k: true, sdl.K_f: true, sdl.K_g: true, sdl.K_w: true, sdl.K_t: true,
+}
+
+// 同一个按键（区分 ctrl、鼠标）只能绑定一次
+func (p *configParser) bind(line int, entry *Entry, keyCode int) bool {
+	key := fmt.Sprintf("%s/%d", entry.Type, keyCode)
+	if first, ok := p.bindings[key]; ok {
+		p.errorf(line, "duplicate binding of %s (first defined at line %d)", entry.Code, first)
+		return false
+	}
+	p.bindings[key] = line
+	return true
+}
+
+func (p *configParser) parseUserOperation(node *yaml.Node, entry *Entry) UserOperation {
+	if entry.Point != nil {
+		line := fieldLine(node, "point")
+		if entry.ShowPointer {
+			sp := new(SPoint)
+			p.addPoint(line, entry.Code, entry.Point, (*Point)(sp))
+			return sp
+		} else {
+			point := new(Point)
+			p.addPoint(line, entry.Code, entry.Point, point)
+			return point
+		}
+	} else if len(entry.Macro) > 0 {
+		var list []*PointMacro
+		macroNode := fieldNode(node, "macro")
+		for i, m := range entry.Macro {
+			line := macroNode.Content[i].Line
+			if m == nil || m.Point == nil {
+				p.errorf(line, "macro of %s missing point", entry.Code)
+				continue
+			}
+			pm := &PointMacro{Interval: time.Duration(m.Delay) * time.Millisecond}
+			p.addPoint(line, entry.Code, m.Point, &pm.Point)
+			list = append(list, pm)
+		}
+		if len(list) < len(entry.Macro) {
+			return nil
+		}
+		return list
+	} else if fieldNode(node, "macro") != nil {
+		p.errorf(node.Line, "empty macro of %s", entry.Code)
+		return nil
+	} else {
+		p.errorf(node.Line, "%s has neither point nor macro", entry.Code)
+		return nil
+	}
+}
+
+func parseKeyCode(code string) (int, error) {
+	if keyCode, ok := KeyCodeConstMap[code]; ok {
+		return keyCode, nil
+	}
+	keyCode := int(sdl.GetKeyFromName(code))
+	if keyCode == sdl.K_UNKNOWN {
+		return 0, fmt.Errorf("unknown key code: %s", code)
+	}
+	return keyCode, nil
+}
+
+func (p *configParser) addPoint(line int, code string, ep *EntryPoint, point *Point) {
+	if ep.X < 0 || ep.Y < 0 {
+		p.errorf(line, "negative point (%g, %g) of %s", ep.X, ep.Y, code)
+		return
+	}
+	// 设备坐标只有 16 位
+	if math.Round(ep.X) > math.MaxUint16 || math.Round(ep.Y) > math.MaxUint16 {
+		p.errorf(line, "point (%g, %g) of %s is out of range (at most %d)", ep.X, ep.Y, code, math.MaxUint16)
+		return
+	}
+	*point = Point{uint16(math.Round(ep.X)), uint16(math.Round(ep.Y))}
+	p.cfg.points = append(p.cfg.points, configPoint{line: line, code: code, x: ep.X, y: ep.Y, p: point})
+}
+
+// 检查所有坐标换算到设备画面后是否在画面之内
+func (cfg *Config) CheckBounds(width, height int) error {
+	w, h := cfg.orient(width, height)
+	problems := cfg.outside(w, h)
+	// 方向段按设备处于该方向时的宽高检查
+	for o, section := range cfg.Orientations {
+		w, h := width, height
+		if orientationOf(w, h) != o {
+			w, h = h, w
+		}
+		problems = append(problems, section.outside(w, h)...)
+	}
+	if len(problems) > 0 {
+		sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+func (cfg *Config) outside(width, height int) []ConfigProblem {
+	var problems []ConfigProblem
+	for _, cp := range cfg.points {
+		p := cfg.scalePoint(cp, width, height)
+		if int(p.X) >= width || int(p.Y) >= height {
+			problems = append(problems, ConfigProblem{Line: cp.line,
+				Message: fmt.Sprintf("point (%g, %g) of %s is outside the device (%dx%d)", cp.x, cp.y, cp.code, width, height)})
+		}
+	}
+	return problems
+}
+
+// 设备横竖屏与参考分辨率不一致时交换宽高；参考分辨率宽高相同（如比例坐标）时按横屏处理
+func (cfg *Config) orient(width, height int) (int, int) {
+	return cfg.Resolution.orient(width, height)
+}
+
+func (r *Resolution) orient(width, height int) (int, int) {
+	if r != nil && width != height && (width > height) != (r.Width >= r.Height) {
+		return height, width
+	}
+	return width, height
+}
+
+func (cfg *Config) scalePoint(cp configPoint, width, height int) Point {
+	r := cfg.Resolution
+	if r == nil {
+		return *cp.p
+	}
+	return Point{
+		X: uint16(math.Round(cp.x * float64(width) / r.Width)),
+		Y: uint16(math.Round(cp.y * float64(height) / r.Height)),
+	}
+}
+
+// 按视频帧的方向选择按键映射：有对应的方向段时使用该段，否则使用 keys
+func (cfg *Config) ForFrame(width, height int) *Config {
+	if section, ok := cfg.Orientations[orientationOf(width, height)]; ok {
+		return section.scaleTo(width, height)
+	}
+	return cfg.ScaleTo(width, height)
+}
+
+// 将坐标换算为 width x height 设备上的像素。没有设置 resolution 时返回 cfg 本身，
+// 否则返回新的 Config，不会修改 cfg（多个设备可能共用同一份配置）
+func (cfg *Config) ScaleTo(width, height int) *Config {
+	width, height = cfg.orient(width, height)
+	return cfg.scaleTo(width, height)
+}
+
+func (cfg *Config) scaleTo(width, height int) *Config {
+	if cfg.Resolution == nil || width == 0 || height == 0 {
+		return cfg
+	}
+
+	scaled := *cfg
+	scaled.Resolution = nil
+	scaled.points = nil
+	index := make(map[*Point]configPoint)
+	for _, cp := range cfg.points {
+		index[cp.p] = cp
+	}
+	scale := func(src, dst *Point) {
+		cp, ok := index[src]
+		if !ok {
+			*dst = *src
+			return
+		}
+		*dst = cfg.scalePoint(cp, width, height)
+		scaled.points = append(scaled.points, configPoint{line: cp.line, code: cp.code, x: float64(dst.X), y: float64(dst.Y), p: dst})
+	}
+
+	scaled.KeyMap = make(map[int]UserOperation)
+	for k, opr := range cfg.KeyMap {
+		scaled.KeyMap[k] = scaleOperation(opr, scale)
+	}
+	scaled.CtrlKeyMap = make(map[int]UserOperation)
+	for k, opr := range cfg.CtrlKeyMap {
+		scaled.CtrlKeyMap[k] = scaleOperation(opr, scale)
+	}
+	scaled.MouseKeyMap = make(map[uint8]UserOperation)
+	for k, opr := range cfg.MouseKeyMap {
+		scaled.MouseKeyMap[k] = scaleOperation(opr, scale)
+	}
+	scaled.GamepadKeyMap = make(map[int]UserOperation)
+	for k, opr := range cfg.GamepadKeyMap {
+		scaled.GamepadKeyMap[k] = scaleOperation(opr, scale)
+	}
+	// 滚轮拖动的步长及范围按所在坐标轴的比例换算，没有设置的步长（0）及范围保持默认值
+	scaled.WheelKeyMap = make(map[int]UserOperation)
+	for k, opr := range cfg.WheelKeyMap {
+		src := opr.(*WheelDrag)
+		wd := *src
+		scale(&src.Point, &wd.Point)
+		ratio := float64(height) / cfg.Resolution.Height
+		if wd.Horizontal {
+			ratio = float64(width) / cfg.Resolution.Width
+		}
+		wd.Step *= ratio
+		wd.Min *= ratio
+		if wd.Max >= 0 {
+			wd.Max *= ratio
+		}
+		scaled.WheelKeyMap[k] = &wd
+	}
+	return &scaled
+}
+
+func scaleOperation(opr UserOperation, scale func(src, dst *Point)) UserOperation {
+	switch o := opr.(type) {
+	case *Point:
+		p := new(Point)
+		scale(o, p)
+		return p
+
+	case *SPoint:
+		sp := new(SPoint)
+		scale((*Point)(o), (*Point)(sp))
+		return sp
+
+	case []*PointMacro:
+		list := make([]*PointMacro, 0, len(o))
+		for _, pm := range o {
+			npm := &PointMacro{Interval: pm.Interval}
+			scale(&pm.Point, &npm.Point)
+			list = append(list, npm)
+		}
+		return list
+	}
+	return opr
+}
+
+func fieldNode(node *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func fieldLine(node *yaml.Node, name string) int {
+	if n := fieldNode(node, name); n != nil {
+		return n.Line
+	}
+	return node.Line
+}
+
+// 定时检查文件的修改时间，发生变化时调用 changed，直到 stop 被关闭。
+// path 每次检查时重新获取，切换到另一个文件后从该文件当前的修改时间开始比较
+func watchFile(path func() string, interval time.Duration, stop <-chan struct{}, changed func()) {
+	modTime := func(path string) time.Time {
+		if info, err := os.Stat(path); err == nil {
+			return info.ModTime()
+		}
+		return time.Time{}
+	}
+	current := path()
+	lastModTime := modTime(current)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if p := path(); p != current {
+				current, lastModTime = p, modTime(p)
+				continue
+			}
+			info, err := os.Stat(current)
+			if err != nil {
+				continue
+			}
+			if !info.ModTime().Equal(lastModTime) {
+				lastModTime = info.ModTime()
+				changed()
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package scrcpy
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 满足必需按键的最小配置，测试在后面追加内容
+const baseConfig = `keys:
+  - { code: SCRCPY_FIRE, point: { x: 10, y: 10 } }
+  - { code: SCRCPY_VISION_TOPLEFT, point: { x: 20, y: 20 } }
+  - { code: SCRCPY_VISION_BOTTOMRIGHT, point: { x: 30, y: 30 } }
+  - { code: SCRCPY_FRONT, point: { x: 40, y: 40 } }
+  - { code: SCRCPY_BACK, point: { x: 50, y: 50 } }
+`
+
+func mustParseConfig(t *testing.T, content string) *Config {
+	t.Helper()
+	cfg, err := ParseConfig([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseConfig: %v", err)
+	}
+	return cfg
+}
+
+func TestWheelScaling(t *testing.T) {
+	cfg := mustParseConfig(t, "resolution: { width: 1, height: 1 }\n"+baseConfig+
+		"  - { code: WHEEL, type: wheel, point: { x: 0.5, y: 0.5 } }\n"+
+		"  - { code: SHIFT+WHEEL, type: wheel, point: { x: 0.5, y: 0.5 }, wheel: { axis: x, step: 0.01, min: 0.25, max: 0.75 } }\n")
+	scaled := cfg.ScaleTo(1920, 1080)
+
+	// 没有设置的步长是设备像素，不随 resolution 换算
+	wd := scaled.WheelKeyMap[0].(*WheelDrag)
+	frame := size{width: 1920, height: 1080}
+	if got := wd.move(540, 1, frame); got != 540+defaultWheelStep {
+		t.Errorf("default step: moved to %g, want %d", got, 540+defaultWheelStep)
+	}
+	if min, max := wd.bounds(frame); min != 0 || max != 1079 {
+		t.Errorf("default bounds = %g~%g, want the whole frame", min, max)
+	}
+
+	// 设置了的值按横轴换算
+	wd = scaled.WheelKeyMap[WheelCodeMap["SHIFT+WHEEL"]].(*WheelDrag)
+	if math.Abs(wd.Step-19.2) > 1e-9 {
+		t.Errorf("step = %g, want 19.2", wd.Step)
+	}
+	if min, max := wd.bounds(frame); min != 480 || max != 1440 {
+		t.Errorf("bounds = %g~%g, want 480~1440", min, max)
+	}
+}
+
+func TestConvertWheelDefaults(t *testing.T) {
+	content := "resolution: { width: 100, height: 100 }\n" + baseConfig +
+		"  - { code: WHEEL, type: wheel, point: { x: 50, y: 50 } }\n"
+	out, err := ConvertConfig([]byte(content), nil, Resolution{Width: 1920, Height: 1080})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), "step") {
+		t.Errorf("conversion added a step to a wheel without one:\n%s", out)
+	}
+	cfg := mustParseConfig(t, string(out))
+	if wd := cfg.ScaleTo(1920, 1080).WheelKeyMap[0].(*WheelDrag); wd.Step != 0 {
+		t.Errorf("step = %g, want the default", wd.Step)
+	}
+}
+
+func configProblems(t *testing.T, err error) []ConfigProblem {
+	t.Helper()
+	ce, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("err = %v, want *ConfigError", err)
+	}
+	return ce.Problems
+}
+
+func TestParseConfigProblems(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []ConfigProblem
+	}{
+		{"unknown field", "colour: red\n" + baseConfig,
+			[]ConfigProblem{{1, `unknown field "colour"`}}},
+		{"unknown entry field", baseConfig + "  - { code: Q, pont: { x: 1, y: 1 } }\n",
+			[]ConfigProblem{{7, `unknown field "pont"`}, {7, "Q has neither point nor macro"}}},
+		{"duplicate binding", baseConfig +
+			"  - { code: Q, point: { x: 1, y: 1 } }\n" +
+			"  - { code: E, point: { x: 1, y: 1 } }\n" +
+			"  - { code: Q, macro: [ { point: { x: 2, y: 2 } } ] }\n",
+			[]ConfigProblem{{9, "duplicate binding of Q (first defined at line 7)"}}},
+		{"ctrl is another binding", baseConfig +
+			"  - { code: Q, point: { x: 1, y: 1 } }\n" +
+			"  - { code: Q, type: ctrl, point: { x: 1, y: 1 } }\n", nil},
+		{"reserved ctrl key", baseConfig + "  - { code: F, type: ctrl, point: { x: 1, y: 1 } }\n",
+			[]ConfigProblem{{7, "ctrl+F is reserved for a built-in shortcut"}}},
+		{"missing required point", "hits: [ 50 ]\n" +
+			strings.Replace(baseConfig, "  - { code: SCRCPY_BACK, point: { x: 50, y: 50 } }\n", "", 1),
+			[]ConfigProblem{{2, "missing point of SCRCPY_BACK"}}},
+		{"required point in section", baseConfig + "portrait:\n  keys:\n    - { code: SCRCPY_FIRE, point: { x: 1, y: 1 } }\n",
+			[]ConfigProblem{
+				{8, "missing point of SCRCPY_VISION_TOPLEFT in portrait"},
+				{8, "missing point of SCRCPY_VISION_BOTTOMRIGHT in portrait"},
+				{8, "missing point of SCRCPY_FRONT in portrait"},
+				{8, "missing point of SCRCPY_BACK in portrait"},
+			}},
+		{"empty macro", baseConfig + "  - { code: Q, macro: [] }\n",
+			[]ConfigProblem{{7, "empty macro of Q"}}},
+		{"macro without point", baseConfig + "  - code: Q\n    macro:\n      - { point: { x: 1, y: 1 } }\n      - { delay: 10 }\n",
+			[]ConfigProblem{{10, "macro of Q missing point"}}},
+		{"neither point nor macro", baseConfig + "  - { code: Q, comment: nothing }\n",
+			[]ConfigProblem{{7, "Q has neither point nor macro"}}},
+		{"unknown type", baseConfig + "  - code: Q\n    type: tripple\n    point: { x: 1, y: 1 }\n",
+			[]ConfigProblem{{7, `unknown type "tripple" of Q`}}},
+		{"negative point", baseConfig + "  - code: Q\n    point: { x: -1, y: 1 }\n",
+			[]ConfigProblem{{8, "negative point (-1, 1) of Q"}}},
+		{"point out of range", baseConfig + "  - code: Q\n    macro:\n      - { point: { x: 65535, y: 65536 } }\n",
+			[]ConfigProblem{{9, "point (65535, 65536) of Q is out of range (at most 65535)"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(tt.content))
+			if tt.want == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if got := configProblems(t, err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("problems = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckBounds(t *testing.T) {
+	cfg := mustParseConfig(t, baseConfig+
+		"  - { code: Q, point: { x: 2000, y: 10 } }\n"+
+		"  - { code: E, point: { x: 1079, y: 2247 } }\n"+
+		"  - code: R\n    macro:\n      - { point: { x: 10, y: 2248 } }\n"+
+		"landscape:\n  keys:\n"+strings.Replace(baseConfig[len("keys:\n"):], "  - ", "    - ", -1)+
+		"    - { code: Q, point: { x: 2000, y: 10 } }\n")
+
+	if err := cfg.CheckBounds(2300, 2300); err != nil {
+		t.Errorf("large device: %v", err)
+	}
+	// landscape 段按横屏检查，x 超出竖屏宽度的 Q 不会报错
+	want := []ConfigProblem{
+		{7, "point (2000, 10) of Q is outside the device (1080x2248)"},
+		{11, "point (10, 2248) of R is outside the device (1080x2248)"},
+	}
+	if got := configProblems(t, cfg.CheckBounds(1080, 2248)); !reflect.DeepEqual(got, want) {
+		t.Errorf("problems = %v, want %v", got, want)
+	}
+
+	// 设置了 resolution 时按换算后的坐标检查，提示中仍是配置文件中的坐标
+	cfg = mustParseConfig(t, "resolution: { width: 1080, height: 2248 }\n"+baseConfig+
+		"  - { code: Q, point: { x: 1080, y: 100 } }\n")
+	want = []ConfigProblem{{8, "point (1080, 100) of Q is outside the device (540x1124)"}}
+	if got := configProblems(t, cfg.CheckBounds(540, 1124)); !reflect.DeepEqual(got, want) {
+		t.Errorf("problems = %v, want %v", got, want)
+	}
+}
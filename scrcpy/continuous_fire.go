@@ -11,6 +11,7 @@ type continuousFire struct {
 	Point
 	state    int
 	id       *int
+	fingers  *fingerState
 	interval time.Duration
 }
 
@@ -34,14 +35,17 @@ func (cf *continuousFire) inProgress(data interface{}) time.Duration {
 		cf.state = cf.state % 2
 		switch cf.state {
 		case 0:
-			cf.id = fingers.GetId()
+			// 手指不够用时等下一次再按
+			if cf.id = cf.fingers.GetId(); cf.id == nil {
+				return cf.interval
+			}
 			cf.sendMouseEvent(c, AMOTION_EVENT_ACTION_DOWN, *cf.id)
 			cf.state++
 			return cf.interval
 
 		case 1:
 			cf.sendMouseEvent(c, AMOTION_EVENT_ACTION_UP, *cf.id)
-			fingers.Recycle(cf.id)
+			cf.fingers.Recycle(cf.id)
 			cf.id = nil
 			cf.state++
 			return cf.interval
@@ -50,7 +54,7 @@ func (cf *continuousFire) inProgress(data interface{}) time.Duration {
 	} else {
 		if cf.id != nil {
 			cf.sendMouseEvent(c, AMOTION_EVENT_ACTION_UP, *cf.id)
-			fingers.Recycle(cf.id)
+			cf.fingers.Recycle(cf.id)
 			cf.id = nil
 		}
 		return 0
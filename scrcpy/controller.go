@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io"
 	"log"
+	"net"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -11,6 +12,7 @@ import (
 
 var errFullQueue = errors.New("full event queue")
 var errStopped = errors.New("queue already stopped")
+var errDisconnected = errors.New("device disconnected")
 
 type controlEventType uint8
 
@@ -95,16 +97,12 @@ func (c *controllerImpl) run() {
 	for {
 		event := <-c.ch
 		if event == nil {
-			for {
-				st := atomic.LoadInt32(&c.stopped)
-				if st != 0 {
-					continue
-				}
-				if atomic.CompareAndSwapInt32(&c.stopped, 0, 1) {
-					close(c.ch)
-				}
+			// 等待正在进行的 PushEvent 结束后关闭队列，之后的 PushEvent 返回 errStopped
+			for !atomic.CompareAndSwapInt32(&c.stopped, 0, 1) {
+				runtime.Gosched()
 			}
-			break
+			close(c.ch)
+			return
 		}
 
 		c.handlerMutex.Lock()
@@ -164,3 +162,26 @@ func defaultControlHandler(c Controller, event interface{}) interface{} {
 
 	return nil
 }
+
+// 重连时只替换底层连接，controller 及其注册的 handler 不需要重建
+type connWriter struct {
+	conn  net.Conn
+	mutex sync.Mutex
+}
+
+func (w *connWriter) Write(b []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.conn == nil {
+		return 0, errDisconnected
+	}
+	return w.conn.Write(b)
+}
+
+func (w *connWriter) set(conn net.Conn) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.conn = conn
+}
@@ -1,6 +1,7 @@
 package scrcpy
 
 import (
+	"sync"
 	"time"
 )
 
@@ -8,18 +9,24 @@ type controllerAnimation struct {
 	pointIntervals []*PointMacro
 	state          int
 	controller     Controller
+	fingers        *fingerState
 	id             *int
+	// 手指不够用时宏手指可能被其他按键抢占，抢占方发送的 UP 与宏自身的事件互斥。
+	// 申请手指时不持有锁（抢占方会锁住被抢占的宏），刚申请到、还没记录就被抢占的手指记在 stolen 中
+	mutex  sync.Mutex
+	stolen *int
 	animator
 }
 
 var eventConstants = []androidMotionEventAction{AMOTION_EVENT_ACTION_DOWN, AMOTION_EVENT_ACTION_UP}
 
-func newControllerAnimation(c Controller, pointIntervals []*PointMacro) *controllerAnimation {
+func newControllerAnimation(c Controller, fingers *fingerState, pointIntervals []*PointMacro) *controllerAnimation {
 	ca := controllerAnimation{
 		pointIntervals: pointIntervals,
 	}
 	ca.InProgress = ca.inProgress
 	ca.controller = c
+	ca.fingers = fingers
 	return &ca
 }
 
@@ -32,18 +39,30 @@ func (ca *controllerAnimation) inProgress(data interface{}) time.Duration {
 	if m >= len(ca.pointIntervals) {
 		panic("error state")
 	}
+	var id *int
 	if n == 0 {
-		ca.id = fingers.GetId()
+		id = ca.fingers.getMacroId(ca.steal)
 	}
-	sme := singleMouseEvent{action: eventConstants[n]}
-	sme.id = *ca.id
-	sme.Point = ca.pointIntervals[m].Point
-	ca.controller.PushEvent(&sme)
-	if n == 1 {
-		fingers.Recycle(ca.id)
-		ca.id = nil
+	ca.mutex.Lock()
+	if n == 0 {
+		if id != nil && id == ca.stolen {
+			id = nil
+		}
+		ca.id, ca.stolen = id, nil
+	}
+	// 没有得到手指或者手指已被抢占时跳过这一步
+	if ca.id != nil {
+		sme := singleMouseEvent{action: eventConstants[n]}
+		sme.id = *ca.id
+		sme.Point = ca.pointIntervals[m].Point
+		ca.controller.PushEvent(&sme)
+		if n == 1 {
+			ca.fingers.Recycle(ca.id)
+			ca.id = nil
+		}
 	}
 	ca.state++
+	ca.mutex.Unlock()
 	if n < 1 {
 		return 30 * time.Millisecond
 	} else if m == len(ca.pointIntervals)-1 {
@@ -52,3 +71,19 @@ func (ca *controllerAnimation) inProgress(data interface{}) time.Duration {
 		return ca.pointIntervals[m].Interval
 	}
 }
+
+func (ca *controllerAnimation) steal(id *int) {
+	ca.mutex.Lock()
+	defer ca.mutex.Unlock()
+
+	if ca.id == id {
+		sme := singleMouseEvent{action: AMOTION_EVENT_ACTION_UP}
+		sme.id = *id
+		sme.Point = ca.pointIntervals[ca.state/2].Point
+		ca.controller.PushEvent(&sme)
+		ca.id = nil
+	} else {
+		// 还没有按下，不需要发送 UP
+		ca.stolen = id
+	}
+}
@@ -0,0 +1,51 @@
+package scrcpy
+
+import (
+	"io/ioutil"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type chanHandler chan interface{}
+
+func (h chanHandler) HandleControlEvent(_ Controller, event interface{}) interface{} {
+	h <- event
+	return nil
+}
+
+// Stop 之前推送的事件仍会处理，之后 goroutine 退出并关闭队列
+func TestControllerStop(t *testing.T) {
+	c := newController(ioutil.Discard).(*controllerImpl)
+	events := make(chanHandler, 3)
+	c.Register(events)
+	c.Start()
+
+	for i := 0; i < 3; i++ {
+		if err := c.PushEvent(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if ev := <-events; ev != i {
+			t.Errorf("event %d = %v", i, ev)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&c.stopped) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("controller did not stop")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := <-c.ch; ok {
+		t.Error("queue is not closed")
+	}
+	if err := c.PushEvent(4); err != errStopped {
+		t.Errorf("push after stop: err = %v, want %v", err, errStopped)
+	}
+}
@@ -0,0 +1,193 @@
+package scrcpy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 将配置文件中的所有坐标从 from 换算到 to，并写入 resolution。
+// from 为空时使用文件中的 resolution。只替换坐标数值，注释及格式保持不变
+func ConvertConfig(content []byte, from *Resolution, to Resolution) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("config must be a mapping")
+	}
+	root := doc.Content[0]
+
+	resNode := fieldNode(root, "resolution")
+	if from == nil {
+		if resNode == nil {
+			return nil, errors.New("config has no resolution, the source resolution must be specified")
+		}
+		from = new(Resolution)
+		if err := resNode.Decode(from); err != nil {
+			return nil, err
+		}
+	}
+	if from.Width <= 0 || from.Height <= 0 || to.Width <= 0 || to.Height <= 0 {
+		return nil, fmt.Errorf("invalid resolution %v -> %v", from, to)
+	}
+
+	var edits textEdits
+	scale := func(node *yaml.Node, ratio float64) error {
+		if node == nil {
+			return nil
+		}
+		v, err := strconv.ParseFloat(node.Value, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid coordinate %q", node.Line, node.Value)
+		}
+		edits.replace(node, formatCoordinate(v*ratio, to))
+		return nil
+	}
+	scalePoint := func(point *yaml.Node, from, to Resolution) error {
+		if point == nil || point.Kind != yaml.MappingNode {
+			return nil
+		}
+		if err := scale(fieldNode(point, "x"), to.Width/from.Width); err != nil {
+			return err
+		}
+		return scale(fieldNode(point, "y"), to.Height/from.Height)
+	}
+	scaleKeys := func(keys *yaml.Node, from, to Resolution) error {
+		if keys == nil {
+			return nil
+		}
+		for _, entry := range keys.Content {
+			if err := scalePoint(fieldNode(entry, "point"), from, to); err != nil {
+				return err
+			}
+			if macro := fieldNode(entry, "macro"); macro != nil {
+				for _, m := range macro.Content {
+					if err := scalePoint(fieldNode(m, "point"), from, to); err != nil {
+						return err
+					}
+				}
+			}
+			// 滚轮拖动的步长及范围是所在坐标轴上的长度
+			if wheel := fieldNode(entry, "wheel"); wheel != nil && wheel.Kind == yaml.MappingNode {
+				ratio := to.Height / from.Height
+				if axis := fieldNode(wheel, "axis"); axis != nil && axis.Value == "x" {
+					ratio = to.Width / from.Width
+				}
+				for _, name := range []string{"step", "min", "max"} {
+					if err := scale(fieldNode(wheel, name), ratio); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := scaleKeys(fieldNode(root, "keys"), *from, to); err != nil {
+		return nil, err
+	}
+
+	// 方向段按该方向旋转后的分辨率换算，段中有 resolution 时以其为准并一起替换
+	for name, o := range orientationNames {
+		section := fieldNode(root, name)
+		if section == nil || section.Kind != yaml.MappingNode {
+			continue
+		}
+		sectionFrom, sectionTo := from.oriented(o), to.oriented(o)
+		if r := fieldNode(section, "resolution"); r != nil {
+			if err := r.Decode(&sectionFrom); err != nil {
+				return nil, err
+			}
+			if w, h := fieldNode(r, "width"), fieldNode(r, "height"); w != nil && h != nil {
+				edits.replace(w, formatCoordinate(sectionTo.Width, to))
+				edits.replace(h, formatCoordinate(sectionTo.Height, to))
+			}
+		}
+		if sectionFrom.Width <= 0 || sectionFrom.Height <= 0 {
+			return nil, fmt.Errorf("line %d: invalid resolution", section.Line)
+		}
+		if err := scaleKeys(fieldNode(section, "keys"), sectionFrom, sectionTo); err != nil {
+			return nil, err
+		}
+	}
+
+	width := formatCoordinate(to.Width, to)
+	height := formatCoordinate(to.Height, to)
+	if resNode == nil {
+		edits.insert(root.Content[0].Line, fmt.Sprintf("resolution: { width: %s, height: %s }", width, height))
+	} else if w, h := fieldNode(resNode, "width"), fieldNode(resNode, "height"); w != nil && h != nil {
+		edits.replace(w, width)
+		edits.replace(h, height)
+	} else {
+		return nil, fmt.Errorf("line %d: invalid resolution", resNode.Line)
+	}
+
+	return edits.apply(content), nil
+}
+
+// 换算到像素时取整，换算到比例坐标时保留 4 位小数
+func formatCoordinate(v float64, to Resolution) string {
+	if to.Width > 1 && to.Height > 1 {
+		return strconv.Itoa(int(math.Round(v)))
+	}
+	return strconv.FormatFloat(math.Round(v*10000)/10000, 'f', -1, 64)
+}
+
+type textEdit struct {
+	line, column int
+	// 被替换的字符数，为 -1 时表示在 line 之前插入一行
+	length int
+	text   string
+}
+
+type textEdits []textEdit
+
+func (e *textEdits) replace(node *yaml.Node, text string) {
+	length := len([]rune(node.Value))
+	if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		length += 2
+	}
+	*e = append(*e, textEdit{line: node.Line, column: node.Column, length: length, text: text})
+}
+
+func (e *textEdits) insert(line int, text string) {
+	*e = append(*e, textEdit{line: line, length: -1, text: text})
+}
+
+// 同一行中从后往前替换，避免列号失效。yaml 的列号以字符计算
+func (e textEdits) apply(content []byte) []byte {
+	sort.SliceStable(e, func(i, j int) bool {
+		if e[i].line != e[j].line {
+			return e[i].line < e[j].line
+		}
+		return e[i].column > e[j].column
+	})
+
+	lines := strings.SplitAfter(string(content), "\n")
+	var b strings.Builder
+	next := 0
+	for i, line := range lines {
+		runes := []rune(line)
+		var inserts []string
+		for ; next < len(e) && e[next].line == i+1; next++ {
+			edit := e[next]
+			if edit.length < 0 {
+				inserts = append(inserts, edit.text+"\n")
+				continue
+			}
+			start := edit.column - 1
+			runes = append(runes[:start], append([]rune(edit.text), runes[start+edit.length:]...)...)
+		}
+		for _, text := range inserts {
+			b.WriteString(text)
+		}
+		b.WriteString(string(runes))
+	}
+	return []byte(b.String())
+}
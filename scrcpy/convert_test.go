@@ -0,0 +1,123 @@
+package scrcpy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvertConfig(t *testing.T) {
+	pixel := &Resolution{Width: 1080, Height: 2248}
+	half := Resolution{Width: 540, Height: 1124}
+	tests := []struct {
+		name    string
+		content string
+		from    *Resolution
+		to      Resolution
+		want    string
+	}{
+		{"comments and formatting", `# 王者荣耀
+resolution:
+  width: 1080   # 手机
+  height: 2248
+keys:
+  # 开火
+  - code: SCRCPY_FIRE
+    point: { x: 100, y: 200 }   # 右下角
+  - {code: Q, point: {x: 1079, y: 2247}, comment: "x: 5"}
+`, nil, half, `# 王者荣耀
+resolution:
+  width: 540   # 手机
+  height: 1124
+keys:
+  # 开火
+  - code: SCRCPY_FIRE
+    point: { x: 50, y: 100 }   # 右下角
+  - {code: Q, point: {x: 540, y: 1124}, comment: "x: 5"}
+`},
+		{"quoted values", `resolution: { width: 1080, height: 2248 }
+keys:
+  - code: Q
+    point:
+      x: "100"
+      y: '202'
+`, nil, half, `resolution: { width: 540, height: 1124 }
+keys:
+  - code: Q
+    point:
+      x: 50
+      y: 101
+`},
+		{"flow style", `resolution: { width: 1080, height: 2248 }
+keys:
+  - { comment: 连招, code: F, macro: [ { point: { x: 10, y: 20 } }, { point: { x: 30, y: 40 }, delay: 5 } ] }
+  - { code: WHEEL, type: wheel, point: { x: 1, y: 2 }, wheel: { axis: x, step: 5, max: 100 } }
+`, nil, Resolution{Width: 2160, Height: 4496}, `resolution: { width: 2160, height: 4496 }
+keys:
+  - { comment: 连招, code: F, macro: [ { point: { x: 20, y: 40 } }, { point: { x: 60, y: 80 }, delay: 5 } ] }
+  - { code: WHEEL, type: wheel, point: { x: 2, y: 4 }, wheel: { axis: x, step: 10, max: 200 } }
+`},
+		{"insert resolution", `# 比例坐标
+keys:
+  - { code: SCRCPY_FIRE, point: { x: 540, y: 562 } }
+`, pixel, Normalized, `# 比例坐标
+resolution: { width: 1, height: 1 }
+keys:
+  - { code: SCRCPY_FIRE, point: { x: 0.5, y: 0.25 } }
+`},
+		{"orientation section", `resolution: { width: 1080, height: 2248 }
+keys:
+  - { code: Q, point: { x: 1080, y: 2248 } }
+landscape:
+  resolution: { width: 2248, height: 1080 }
+  keys:
+    - { code: Q, point: { x: 2248, y: 1080 } }
+portrait:
+  keys:
+    - { code: Q, point: { x: 10, y: 20 } }
+`, nil, half, `resolution: { width: 540, height: 1124 }
+keys:
+  - { code: Q, point: { x: 540, y: 1124 } }
+landscape:
+  resolution: { width: 1124, height: 540 }
+  keys:
+    - { code: Q, point: { x: 1124, y: 540 } }
+portrait:
+  keys:
+    - { code: Q, point: { x: 5, y: 10 } }
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertConfig([]byte(tt.content), tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		from    *Resolution
+		err     string
+	}{
+		{"no resolution", "keys: []\n", nil, "source resolution must be specified"},
+		{"invalid coordinate", "keys:\n  - { code: Q, point: { x: left, y: 1 } }\n",
+			&Resolution{Width: 1, Height: 1}, `line 2: invalid coordinate "left"`},
+		{"invalid resolution", "resolution: { width: 0, height: 1 }\n", nil, "invalid resolution"},
+		{"not a mapping", "- a\n", nil, "must be a mapping"},
+	}
+
+	for _, tt := range tests {
+		_, err := ConvertConfig([]byte(tt.content), tt.from, Normalized)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
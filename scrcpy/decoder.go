@@ -0,0 +1,158 @@
+package scrcpy
+
+import (
+	"log"
+	"net"
+
+	"github.com/ClarkGuan/go-sdl2/sdl"
+)
+
+const eventNewFrame = sdl.USEREVENT + 1
+const eventDecoderStopped = sdl.USEREVENT + 2
+const eventFrameSizeChanged = sdl.USEREVENT + 6
+
+// 解码方式
+const (
+	DecoderAuto     = "auto"
+	DecoderSoftware = "software"
+)
+
+// 解码得到的一帧图像，NV12 格式有 2 个平面，IYUV 格式有 3 个平面
+type videoFrame interface {
+	width() int
+	height() int
+	pixelFormat() uint32
+	data(i int) []byte
+	lineSize(i int) int
+}
+
+// 视频解码器，libav 实现见 frame.go，纯 Go 实现见 decoder_fake.go
+type Decoder interface {
+	Start() error
+	Stop() error
+	SetListener(l DecoderListener)
+	// 在解码器内部锁的保护下访问最新的一帧
+	ConsumeFrame(fn func(frame videoFrame) error) error
+}
+
+// 解码器事件回调，均在解码线程中调用
+type DecoderListener interface {
+	// 有新的帧可以通过 ConsumeFrame 获取（上一帧未被消费时不会重复通知）
+	OnNewFrame()
+	OnFrameSizeChanged(old, new size)
+	OnDecoderStopped()
+}
+
+type decoderOption struct {
+	// 解码方式，见 DecoderAuto、DecoderSoftware
+	name string
+	// 录像文件路径，为空时不录像
+	recordPath string
+	// 设备初始的视频尺寸，用于录像文件头
+	frameSize size
+	// 不显示画面时只解析码流，不进行解码
+	noDisplay bool
+}
+
+func newDecoder(opt *decoderOption, sock net.Conn) (Decoder, error) {
+	d, err := newLibavDecoder(opt, sock)
+	if err != nil {
+		// 不能返回包装了 nil *libavDecoder 的接口，调用者会判断为非 nil
+		return nil, err
+	}
+	return d, nil
+}
+
+// 默认的回调实现：转换为 SDL 自定义事件，交由 SDL 线程处理
+type sdlDecoderListener struct {
+	poster eventPoster
+}
+
+func (l sdlDecoderListener) OnNewFrame() {
+	l.poster.push(eventNewFrame, 0)
+}
+
+func (l sdlDecoderListener) OnFrameSizeChanged(old, new size) {
+	l.poster.push(eventFrameSizeChanged, int32(new.width)<<16|int32(new.height))
+}
+
+func (l sdlDecoderListener) OnDecoderStopped() {
+	l.poster.push(eventDecoderStopped, 0)
+}
+
+// 将 NV12 的两个平面拷贝到一块连续内存中
+func copyNV12(frame videoFrame, b []byte) []byte {
+	buf1, buf2 := frame.data(0), frame.data(1)
+	if buf1 == nil || buf2 == nil {
+		return b
+	}
+	if len(b) < len(buf1)+len(buf2) {
+		b = make([]byte, len(buf1)+len(buf2))
+	}
+	n := copy(b, buf1)
+	copy(b[n:], buf2)
+	return b
+}
+
+func (s *screen) updateFrame(d Decoder) error {
+	if err := d.ConsumeFrame(func(frame videoFrame) error {
+		if err := s.prepareForFrame(size{width: uint16(frame.width()),
+			height: uint16(frame.height())}); err != nil {
+			return err
+		}
+
+		if err := s.prepareTextureFormat(frame.pixelFormat()); err != nil {
+			return err
+		}
+
+		return s.updateTexture(frame)
+	}); err != nil {
+		return err
+	}
+
+	s.render()
+	return nil
+}
+
+func (s *screen) updateTexture(frame videoFrame) error {
+	if s.textureFormat == sdl.PIXELFORMAT_IYUV {
+		y, u, v := frame.data(0), frame.data(1), frame.data(2)
+		if y == nil || u == nil || v == nil {
+			return nil
+		}
+		return s.texture.UpdateYUV(nil, y, frame.lineSize(0), u, frame.lineSize(1), v, frame.lineSize(2))
+	}
+
+	s.bufs = copyNV12(frame, s.bufs)
+	if s.bufs == nil {
+		return nil
+	}
+	return s.texture.Update(nil, s.bufs, frame.lineSize(0))
+}
+
+type frameHandler struct {
+	screen  *screen
+	decoder Decoder
+}
+
+func (fh *frameHandler) HandleSdlEvent(event sdl.Event) (bool, error) {
+	switch event.GetType() {
+	case eventNewFrame:
+		if !fh.screen.hasFrame {
+			fh.screen.hasFrame = true
+			fh.screen.showWindow()
+		}
+		if err := fh.screen.updateFrame(fh.decoder); err != nil {
+			return true, err
+		}
+
+	case eventFrameSizeChanged:
+		if debugOpt.Debug() {
+			code := event.(*sdl.UserEvent).Code
+			log.Printf("Video frame size changed: %d, %d\n", code>>16, code&0xffff)
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,131 @@
+package scrcpy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ClarkGuan/go-sdl2/sdl"
+)
+
+// 纯 Go 实现的帧，内容为单一颜色的 IYUV 图像
+type fakeFrame struct {
+	w, h   int
+	planes [3][]byte
+}
+
+func newFakeFrame(s size, luma byte) *fakeFrame {
+	f := &fakeFrame{w: int(s.width), h: int(s.height)}
+	f.planes[0] = make([]byte, f.w*f.h)
+	for i := range f.planes[0] {
+		f.planes[0][i] = luma
+	}
+	for i := 1; i < 3; i++ {
+		f.planes[i] = make([]byte, f.w*f.h>>2)
+		for j := range f.planes[i] {
+			f.planes[i][j] = 0x80
+		}
+	}
+	return f
+}
+
+func (f *fakeFrame) width() int {
+	return f.w
+}
+
+func (f *fakeFrame) height() int {
+	return f.h
+}
+
+func (f *fakeFrame) pixelFormat() uint32 {
+	return sdl.PIXELFORMAT_IYUV
+}
+
+func (f *fakeFrame) data(i int) []byte {
+	return f.planes[i]
+}
+
+func (f *fakeFrame) lineSize(i int) int {
+	if i > 0 {
+		return f.w >> 1
+	}
+	return f.w
+}
+
+// 不依赖设备和 libav 的解码器，依次按 sizes 中的尺寸生成帧，全部生成后视为视频流结束
+type fakeDecoder struct {
+	sizes    []size
+	interval time.Duration
+	listener DecoderListener
+
+	mutex    sync.Mutex
+	current  *fakeFrame
+	consumed bool
+	lastSize size
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newFakeDecoder(interval time.Duration, sizes ...size) *fakeDecoder {
+	return &fakeDecoder{sizes: sizes, interval: interval, listener: sdlDecoderListener{}, consumed: true}
+}
+
+func (d *fakeDecoder) SetListener(l DecoderListener) {
+	d.listener = l
+}
+
+func (d *fakeDecoder) Start() error {
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	go d.run()
+	return nil
+}
+
+func (d *fakeDecoder) run() {
+	defer close(d.done)
+	defer d.listener.OnDecoderStopped()
+
+	for i, s := range d.sizes {
+		select {
+		case <-d.stop:
+			return
+		case <-time.After(d.interval):
+		}
+
+		if s != d.lastSize {
+			old := d.lastSize
+			d.lastSize = s
+			d.listener.OnFrameSizeChanged(old, s)
+		}
+
+		d.mutex.Lock()
+		d.current = newFakeFrame(s, byte(i))
+		prev := d.consumed
+		d.consumed = false
+		d.mutex.Unlock()
+
+		if prev {
+			d.listener.OnNewFrame()
+		}
+	}
+}
+
+func (d *fakeDecoder) Stop() error {
+	if d.stop != nil {
+		close(d.stop)
+		<-d.done
+		d.stop = nil
+	}
+	return nil
+}
+
+func (d *fakeDecoder) ConsumeFrame(fn func(frame videoFrame) error) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.consumed {
+		panic("renderingFrameConsumed state error")
+	}
+	d.consumed = true
+	return fn(d.current)
+}
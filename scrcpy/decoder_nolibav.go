@@ -0,0 +1,14 @@
+//go:build nolibav
+// +build nolibav
+
+package scrcpy
+
+import (
+	"errors"
+	"net"
+)
+
+// 不链接 libav 时（例如 go test -tags nolibav）没有真正的解码器可用
+func newLibavDecoder(opt *decoderOption, sock net.Conn) (Decoder, error) {
+	return nil, errors.New("built without libav, video decoding is unavailable")
+}
@@ -0,0 +1,176 @@
+package scrcpy
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ClarkGuan/go-sdl2/sdl"
+)
+
+type recordingListener struct {
+	frames  chan struct{}
+	sizes   chan size
+	stopped chan struct{}
+}
+
+func newRecordingListener() *recordingListener {
+	return &recordingListener{
+		frames:  make(chan struct{}, 16),
+		sizes:   make(chan size, 16),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (l *recordingListener) OnNewFrame() {
+	l.frames <- struct{}{}
+}
+
+func (l *recordingListener) OnFrameSizeChanged(old, new size) {
+	l.sizes <- new
+}
+
+func (l *recordingListener) OnDecoderStopped() {
+	close(l.stopped)
+}
+
+func TestFakeDecoderFrames(t *testing.T) {
+	portrait := size{width: 1080, height: 2248}
+	landscape := size{width: 2248, height: 1080}
+	d := newFakeDecoder(20*time.Millisecond, portrait, portrait, landscape)
+	l := newRecordingListener()
+	d.SetListener(l)
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	var got []size
+	for done := false; !done; {
+		select {
+		case <-l.frames:
+			if err := d.ConsumeFrame(func(frame videoFrame) error {
+				s := size{width: uint16(frame.width()), height: uint16(frame.height())}
+				if len(frame.data(0)) != frame.lineSize(0)*frame.height() {
+					t.Errorf("%v: unexpected luma plane length %d", s, len(frame.data(0)))
+				}
+				if len(frame.data(1)) != frame.lineSize(1)*frame.height()>>1 {
+					t.Errorf("%v: unexpected chroma plane length %d", s, len(frame.data(1)))
+				}
+				got = append(got, s)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+		case <-l.stopped:
+			done = true
+
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for frames")
+		}
+	}
+
+	if len(got) == 0 || got[len(got)-1] != landscape {
+		t.Errorf("last frame size = %v, want %v", got, landscape)
+	}
+
+	close(l.sizes)
+	var changes []size
+	for s := range l.sizes {
+		changes = append(changes, s)
+	}
+	if len(changes) != 2 || changes[0] != portrait || changes[1] != landscape {
+		t.Errorf("size changes = %v, want [%v %v]", changes, portrait, landscape)
+	}
+}
+
+func TestFakeDecoderStop(t *testing.T) {
+	d := newFakeDecoder(time.Hour, size{width: 16, height: 16})
+	l := newRecordingListener()
+	d.SetListener(l)
+	d.Start()
+	d.Stop()
+
+	select {
+	case <-l.stopped:
+	default:
+		t.Fatal("listener not notified after Stop")
+	}
+}
+
+func TestNewDecoderError(t *testing.T) {
+	d, err := newDecoder(&decoderOption{name: "no-such-decoder"}, nil)
+	if err == nil {
+		d.Stop()
+		t.Fatal("want error for an unknown decoder")
+	}
+	if d != nil {
+		t.Errorf("decoder = %#v, want nil", d)
+	}
+}
+
+// 由 frameHandler 消费假解码器的帧，设备旋转后纹理及窗口跟随新的尺寸
+func TestFrameHandlerResize(t *testing.T) {
+	// 不需要显示器，使用 SDL 的 dummy 视频驱动及软件渲染
+	os.Setenv("SDL_VIDEODRIVER", "dummy")
+	os.Setenv("SDL_RENDER_DRIVER", "software")
+	if err := sdl.Init(sdl.INIT_VIDEO); err != nil {
+		t.Skip("SDL video is unavailable:", err)
+	}
+	defer sdl.Quit()
+
+	portrait := size{width: 1080, height: 2248}
+	landscape := size{width: 2248, height: 1080}
+	var s screen
+	if err := s.InitRendering("test", portrait); err != nil {
+		t.Skip("cannot create a window:", err)
+	}
+	defer s.Close()
+
+	d := newFakeDecoder(10*time.Millisecond, portrait, landscape)
+	l := newRecordingListener()
+	d.SetListener(l)
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	fh := &frameHandler{screen: &s, decoder: d}
+	newFrame := &sdl.UserEvent{Type: uint32(eventNewFrame)}
+	var frames []size
+	for done := false; !done; {
+		select {
+		case <-l.frames:
+			if _, err := fh.HandleSdlEvent(newFrame); err != nil {
+				t.Fatal(err)
+			}
+			frames = append(frames, s.frameSize)
+		case <-l.stopped:
+			done = true
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for frames")
+		}
+	}
+
+	if len(frames) == 0 || s.frameSize != landscape {
+		t.Fatalf("frame sizes = %v, want %v at last", frames, landscape)
+	}
+	if !s.hasFrame {
+		t.Error("window not shown after the first frame")
+	}
+	if s.textureFormat != sdl.PIXELFORMAT_IYUV {
+		t.Errorf("texture format = %d, want IYUV", s.textureFormat)
+	}
+	// 窗口按新的画面比例调整
+	w, h := s.window.GetSize()
+	if w <= h {
+		t.Fatalf("window %dx%d is not landscape", w, h)
+	}
+	ratio := float64(w) / float64(h)
+	want := float64(landscape.width) / float64(landscape.height)
+	if math.Abs(ratio-want) > 0.02*want {
+		t.Errorf("window ratio = %.3f, want %.3f", ratio, want)
+	}
+}
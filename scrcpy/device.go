@@ -1,14 +1,37 @@
 package scrcpy
 
-import "io"
+import (
+	"io"
+	"log"
+)
 
 const deviceNameLength = 64
 
-func (svr *server) ReadDeviceInfo() (deviceName string, screenSize size, err error) {
+// 新版本的服务端先发送握手头；旧版本直接发送设备名称，开头不会是 magic
+func (svr *server) ReadDeviceInfo() (deviceName string, screenSize size, caps capabilities, err error) {
 	buf := make([]byte, deviceNameLength+4)
-	if _, err = io.ReadFull(svr.deviceConn, buf); err != nil {
+	magicLength := len(handshakeMagic)
+	if _, err = io.ReadFull(svr.deviceConn, buf[:magicLength]); err != nil {
 		return
 	}
+	if string(buf[:magicLength]) == handshakeMagic {
+		if caps, err = readCapabilities(svr.deviceConn); err != nil {
+			return
+		}
+		if err = svr.checkCapabilities(caps); err != nil {
+			return
+		}
+		if _, err = io.ReadFull(svr.deviceConn, buf); err != nil {
+			return
+		}
+	} else {
+		caps = legacyCapabilities
+		if _, err = io.ReadFull(svr.deviceConn, buf[magicLength:]); err != nil {
+			return
+		}
+		log.Printf("warning: the device server does not report a protocol version, scroll events and device messages are disabled; %s\n",
+			svr.jarHint())
+	}
 
 	deviceName = string(buf[:deviceNameLength])
 	screenSize.width = uint16(buf[deviceNameLength])<<8 | uint16(buf[deviceNameLength+1])
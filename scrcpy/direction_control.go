@@ -1,10 +1,9 @@
 package scrcpy
 
 import (
+	"math"
 	"sync/atomic"
 	"time"
-
-	"github.com/ClarkGuan/go-sdl2/sdl"
 )
 
 type Direction int
@@ -27,9 +26,14 @@ type directionController struct {
 	middlePoint *Point
 	radius      uint16
 	keyMap      map[int]UserOperation
+	fingers     *fingerState
+	poster      eventPoster
 	id          *int
 	startFlag   int32
 	animator
+
+	// 手柄左摇杆的偏移（-1~1），不为 0 时代替四个方向键
+	stickX, stickY float64
 }
 
 func (dc *directionController) frontDown() {
@@ -80,8 +84,19 @@ func (dc *directionController) isRightDown() bool {
 	return dc.direction&rightDirection != 0
 }
 
+func (dc *directionController) setStick(x, y float64) {
+	dc.stickX, dc.stickY = x, y
+}
+
 func (dc *directionController) allUp() bool {
-	return dc.direction == 0
+	return dc.direction == 0 && dc.stickX == 0 && dc.stickY == 0
+}
+
+func (dc *directionController) reset() {
+	dc.direction = 0
+	dc.stickX, dc.stickY = 0, 0
+	dc.id = nil
+	atomic.StoreInt32(&dc.startFlag, 0)
 }
 
 func (dc *directionController) prepare() {
@@ -98,6 +113,20 @@ func (dc *directionController) getPoint(repeat bool) *Point {
 	dc.prepare()
 	dc.cachePoint = *dc.middlePoint
 
+	// 摇杆按偏移的比例移动，推到底时与方向键相同
+	if dc.direction == 0 && (dc.stickX != 0 || dc.stickY != 0) {
+		x := int(dc.middlePoint.X) + int(math.Round(dc.stickX*float64(dc.radius)))
+		y := int(dc.middlePoint.Y) + int(math.Round(dc.stickY*float64(dc.radius)))
+		if x < 0 {
+			x = 0
+		}
+		if y < 0 {
+			y = 0
+		}
+		dc.cachePoint = Point{uint16(x), uint16(y)}
+		return &dc.cachePoint
+	}
+
 	if dc.isFrontDown() {
 		dc.cachePoint.Y -= dc.radius
 	}
@@ -148,7 +177,7 @@ func (dc *directionController) inProgress(data interface{}) time.Duration {
 	if atomic.LoadInt32(&dc.startFlag) == 0 {
 		return 0
 	} else {
-		sdl.PushEvent(&sdl.UserEvent{Type: eventDirectionEvent})
+		dc.poster.push(eventDirectionEvent, 0)
 		return time.Millisecond * 80
 	}
 }
@@ -160,7 +189,10 @@ func (dc *directionController) sendMouseEvent(controller Controller) error {
 			return nil
 		}
 
-		dc.id = fingers.GetId()
+		// 手指不够用时在下一次定时事件中重试
+		if dc.id = dc.fingers.GetId(); dc.id == nil {
+			return nil
+		}
 		point := dc.getPoint(false)
 		sme := singleMouseEvent{action: AMOTION_EVENT_ACTION_DOWN}
 		sme.id = *dc.id
@@ -179,7 +211,7 @@ func (dc *directionController) sendMouseEvent(controller Controller) error {
 		sme.Point = *point
 		b := controller.PushEvent(&sme)
 		if dc.allUp() {
-			fingers.Recycle(dc.id)
+			dc.fingers.Recycle(dc.id)
 			dc.id = nil
 			atomic.StoreInt32(&dc.startFlag, 0)
 		}
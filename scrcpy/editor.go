@@ -0,0 +1,508 @@
+package scrcpy
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ClarkGuan/go-sdl2/sdl"
+	"gopkg.in/yaml.v3"
+)
+
+const editorHelp = "编辑模式：拖动圆圈调整位置，点击空白处后按键新增，Ctrl+S 保存，Ctrl+E 退出"
+
+// 编辑模式中不能绑定的按键，这些按键有固定的功能
+var editorReservedKeys = map[sdl.Keycode]bool{
+	sdl.K_w: true, sdl.K_a: true, sdl.K_s: true, sdl.K_d: true,
+	sdl.K_UP: true, sdl.K_DOWN: true, sdl.K_LEFT: true, sdl.K_RIGHT: true,
+	sdl.K_RETURN: true, sdl.K_ESCAPE: true,
+}
+
+// 编辑模式中显示的一个坐标
+type editorPoint struct {
+	label string
+	// 设备画面坐标
+	x, y int32
+	// 配置文件中的坐标节点，新增的按键为空
+	xNode, yNode *yaml.Node
+	macro        bool
+	moved        bool
+
+	// 新增的按键
+	code string
+	ctrl bool
+}
+
+// ctrl+e 进入的按键映射编辑模式：在画面上显示所有按键的位置，可以拖动调整或者新增按键，
+// 保存时只修改配置文件中对应的坐标数值或者追加新的行，注释及格式保持不变
+type keyEditor struct {
+	screen  *screen
+	refresh func()
+	path    func() string
+	// 进入编辑模式前松开所有按下的手指
+	onEnter func()
+	toast   func(text string)
+
+	active   bool
+	relative bool
+	file     string
+	content  []byte
+	// 正在编辑的方向段，为空时编辑顶层的 keys
+	section  string
+	parent   *yaml.Node
+	keys     *yaml.Node
+	res      *Resolution
+	points   []*editorPoint
+	dragging *editorPoint
+	pending  *editorPoint
+	dirty    bool
+
+	font      *Font
+	labels    labelCache
+	status    TextTexture
+	statusPos sdl.Rect
+}
+
+func (e *keyEditor) Init(r sdl.Renderer) {
+	var err error
+	if e.font == nil {
+		if e.font, err = openDefaultFont(20); err != nil {
+			panic(err)
+		}
+	}
+	e.labels.font = e.font
+}
+
+func (e *keyEditor) radius() int32 {
+	return int32(e.screen.frameSize.height) / 40
+}
+
+func (e *keyEditor) Render(r sdl.Renderer) {
+	if !e.active {
+		return
+	}
+
+	r.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	radius := e.radius()
+	for _, p := range e.points {
+		switch {
+		case p == e.dragging || p == e.pending:
+			r.SetDrawColor(0, 200, 80, 200)
+		case p.macro:
+			r.SetDrawColor(255, 140, 0, 160)
+		default:
+			r.SetDrawColor(0, 120, 255, 160)
+		}
+		fillCircle(r, p.x, p.y, radius)
+		e.labels.render(r, p.label, sdl.Color{R: 255, G: 255, B: 255, A: 255}, p.x, p.y)
+	}
+	r.SetDrawBlendMode(sdl.BLENDMODE_NONE)
+
+	text := editorHelp
+	if e.pending != nil {
+		text = "按下要绑定的按键（可同时按住 Ctrl），Esc 取消"
+	} else if e.dirty {
+		text += "（有未保存的修改）"
+	}
+	if len(e.section) > 0 {
+		text = fmt.Sprintf("[%s] %s", e.section, text)
+	}
+	e.statusPos.X = 50
+	e.statusPos.Y = int32(e.screen.frameSize.height) - 60
+	e.status.Update(r, e.font, text, sdl.Color{R: 255, G: 255, B: 255, A: 255}, &e.statusPos)
+	e.status.Render(r, &e.statusPos)
+}
+
+func fillCircle(r sdl.Renderer, cx, cy, radius int32) {
+	for dy := -radius; dy <= radius; dy++ {
+		dx := int32(math.Sqrt(float64(radius*radius - dy*dy)))
+		r.FillRect(&sdl.Rect{X: cx - dx, Y: cy + dy, W: 2*dx + 1, H: 1})
+	}
+}
+
+func (e *keyEditor) HandleSdlEvent(event sdl.Event) (bool, error) {
+	switch event.GetType() {
+	case sdl.KEYDOWN, sdl.KEYUP:
+		ke := event.(*sdl.KeyboardEvent)
+		if ke.Keysym.Mod&sdl.KMOD_CTRL != 0 && ke.Keysym.Sym == sdl.K_e {
+			if ke.Type == sdl.KEYDOWN && ke.Repeat == 0 {
+				e.toggle()
+			}
+			return true, nil
+		}
+		if !e.active {
+			return false, nil
+		}
+		if ke.Type == sdl.KEYDOWN {
+			e.handleKeyDown(ke)
+		}
+		return true, nil
+
+	case sdl.MOUSEBUTTONDOWN:
+		if !e.active {
+			return false, nil
+		}
+		if me := event.(*sdl.MouseButtonEvent); me.Button == sdl.BUTTON_LEFT {
+			p := e.screen.devicePoint(me.X, me.Y)
+			e.handleMouseDown(int32(p.X), int32(p.Y))
+		}
+		return true, nil
+
+	case sdl.MOUSEMOTION:
+		if !e.active {
+			return false, nil
+		}
+		if me := event.(*sdl.MouseMotionEvent); e.dragging != nil {
+			p := e.screen.devicePoint(me.X, me.Y)
+			e.dragging.x, e.dragging.y = int32(p.X), int32(p.Y)
+			e.refresh()
+		}
+		return true, nil
+
+	case sdl.MOUSEBUTTONUP:
+		if !e.active {
+			return false, nil
+		}
+		if e.dragging != nil {
+			e.dragging.moved = true
+			e.dragging = nil
+			e.dirty = true
+			e.refresh()
+		}
+		return true, nil
+
+	case sdl.MOUSEWHEEL, sdl.TEXTINPUT:
+		return e.active, nil
+	}
+	return false, nil
+}
+
+func (e *keyEditor) toggle() {
+	if e.active {
+		e.active = false
+		e.dragging, e.pending = nil, nil
+		sdl.SetRelativeMouseMode(e.relative)
+		if e.dirty {
+			e.toast("已退出编辑模式，未保存的修改已丢弃")
+		}
+		e.refresh()
+		return
+	}
+
+	if err := e.load(); err != nil {
+		log.Println("editor:", err)
+		e.toast(fmt.Sprintf("无法编辑配置：%v", err))
+		return
+	}
+	e.onEnter()
+	e.active = true
+	e.relative = sdl.GetRelativeMouseMode()
+	sdl.SetRelativeMouseMode(false)
+	e.refresh()
+}
+
+func (e *keyEditor) handleMouseDown(x, y int32) {
+	if e.pending != nil {
+		e.pending.x, e.pending.y = x, y
+		e.refresh()
+		return
+	}
+
+	radius := e.radius()
+	for i := len(e.points) - 1; i >= 0; i-- {
+		p := e.points[i]
+		if dx, dy := p.x-x, p.y-y; dx*dx+dy*dy <= radius*radius {
+			e.dragging = p
+			return
+		}
+	}
+	e.pending = &editorPoint{x: x, y: y}
+	e.points = append(e.points, e.pending)
+	e.refresh()
+}
+
+func (e *keyEditor) handleKeyDown(event *sdl.KeyboardEvent) {
+	sym := event.Keysym.Sym
+	ctrl := event.Keysym.Mod&sdl.KMOD_CTRL != 0
+
+	if e.pending == nil {
+		if ctrl && sym == sdl.K_s && event.Repeat == 0 {
+			if err := e.save(); err != nil {
+				log.Println("editor:", err)
+				e.toast(fmt.Sprintf("保存失败：%v", err))
+			} else {
+				e.toast("已保存到 " + e.file)
+			}
+			e.refresh()
+		}
+		return
+	}
+
+	// 只按下 Ctrl 等修饰键时继续等待（Shift 可以单独绑定）
+	switch sym {
+	case sdl.K_LCTRL, sdl.K_RCTRL, sdl.K_LALT, sdl.K_RALT, sdl.K_LGUI, sdl.K_RGUI:
+		return
+	}
+
+	if sym == sdl.K_ESCAPE {
+		e.removePending()
+		e.refresh()
+		return
+	}
+	code := sdl.GetKeyName(sym)
+	if len(code) == 0 || editorReservedKeys[sym] || (ctrl && ctrlReservedKeys[sym]) || (!ctrl && sym >= sdl.K_F1 && sym <= sdl.K_F12) {
+		e.toast(fmt.Sprintf("按键 %s 不能绑定", code))
+		return
+	}
+
+	p := e.pending
+	e.removePending()
+	if exist := e.find(code, ctrl); exist != nil {
+		if exist.macro {
+			e.toast(fmt.Sprintf("%s 已绑定为宏，请拖动宏中的坐标", exist.label))
+		} else {
+			exist.x, exist.y, exist.moved = p.x, p.y, true
+			e.dirty = true
+		}
+	} else {
+		p.code, p.ctrl, p.label = code, ctrl, editorLabel(code, ctrl)
+		e.points = append(e.points, p)
+		e.dirty = true
+	}
+	e.refresh()
+}
+
+func (e *keyEditor) removePending() {
+	for i, p := range e.points {
+		if p == e.pending {
+			e.points = append(e.points[:i], e.points[i+1:]...)
+			break
+		}
+	}
+	e.pending = nil
+}
+
+func (e *keyEditor) find(code string, ctrl bool) *editorPoint {
+	label := editorLabel(code, ctrl)
+	for _, p := range e.points {
+		if strings.EqualFold(p.label, label) {
+			return p
+		}
+	}
+	return nil
+}
+
+func editorLabel(code string, ctrl bool) string {
+	if ctrl {
+		return "Ctrl+" + code
+	}
+	return code
+}
+
+// 读取当前的配置文件
+func (e *keyEditor) load() error {
+	e.file = e.path()
+	if len(e.file) == 0 {
+		return errors.New("no config file")
+	}
+	content, err := ioutil.ReadFile(e.file)
+	if err != nil {
+		return err
+	}
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+
+	var doc yaml.Node
+	if err = yaml.Unmarshal(content, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return errors.New("config must be a mapping")
+	}
+	root := doc.Content[0]
+
+	e.res = nil
+	if resNode := fieldNode(root, "resolution"); resNode != nil {
+		e.res = new(Resolution)
+		if err = resNode.Decode(e.res); err != nil {
+			return err
+		}
+	}
+
+	// 与 Config.ForFrame 一样，设备当前的方向有对应的方向段时编辑该段，
+	// 段中没有 resolution 时使用旋转后的 resolution
+	e.section, e.parent = "", root
+	o := orientationOf(int(e.screen.frameSize.width), int(e.screen.frameSize.height))
+	if section := fieldNode(root, o.String()); section != nil {
+		if section.Kind != yaml.MappingNode {
+			return fmt.Errorf("line %d: %v must be a mapping", section.Line, o)
+		}
+		e.section, e.parent = o.String(), section
+		if resNode := fieldNode(section, "resolution"); resNode != nil {
+			e.res = new(Resolution)
+			if err = resNode.Decode(e.res); err != nil {
+				return err
+			}
+		} else if e.res != nil {
+			r := e.res.oriented(o)
+			e.res = &r
+		}
+	}
+
+	e.content = content
+	e.keys = fieldNode(e.parent, "keys")
+	e.points = nil
+	e.dirty = false
+	if e.keys == nil {
+		return nil
+	}
+	if e.keys.Kind != yaml.SequenceNode {
+		return fmt.Errorf("line %d: keys must be a list", e.keys.Line)
+	}
+
+	for _, entry := range e.keys.Content {
+		codeNode := fieldNode(entry, "code")
+		if codeNode == nil {
+			continue
+		}
+		var typ string
+		if typNode := fieldNode(entry, "type"); typNode != nil {
+			typ = typNode.Value
+		}
+		label := codeNode.Value
+		if typ == "ctrl" {
+			label = editorLabel(label, true)
+		}
+
+		if point := fieldNode(entry, "point"); point != nil {
+			e.addPoint(label, point, false)
+		}
+		if macro := fieldNode(entry, "macro"); macro != nil {
+			for i, m := range macro.Content {
+				if point := fieldNode(m, "point"); point != nil {
+					e.addPoint(fmt.Sprintf("%s#%d", label, i+1), point, true)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (e *keyEditor) addPoint(label string, point *yaml.Node, macro bool) {
+	xNode, yNode := fieldNode(point, "x"), fieldNode(point, "y")
+	if xNode == nil || yNode == nil {
+		return
+	}
+	x, errX := strconv.ParseFloat(xNode.Value, 64)
+	y, errY := strconv.ParseFloat(yNode.Value, 64)
+	if errX != nil || errY != nil {
+		return
+	}
+
+	width, height := e.deviceSize()
+	if e.res != nil {
+		x = x * width / e.res.Width
+		y = y * height / e.res.Height
+	}
+	e.points = append(e.points, &editorPoint{label: label, x: int32(math.Round(x)), y: int32(math.Round(y)),
+		xNode: xNode, yNode: yNode, macro: macro})
+}
+
+// 与换算坐标时一样：顶层的 keys 按照参考分辨率的横竖屏对应设备的宽高，方向段直接使用画面的宽高
+func (e *keyEditor) deviceSize() (float64, float64) {
+	width, height := int(e.screen.frameSize.width), int(e.screen.frameSize.height)
+	if len(e.section) == 0 {
+		width, height = e.res.orient(width, height)
+	}
+	return float64(width), float64(height)
+}
+
+// 将设备画面坐标换算回配置文件中的坐标
+func (e *keyEditor) format(v int32, device, ref float64) string {
+	if e.res == nil {
+		return strconv.Itoa(int(v))
+	}
+	return formatCoordinate(float64(v)*ref/device, *e.res)
+}
+
+func (e *keyEditor) save() error {
+	if !e.dirty {
+		return nil
+	}
+
+	width, height := e.deviceSize()
+	var refWidth, refHeight float64
+	if e.res != nil {
+		refWidth, refHeight = e.res.Width, e.res.Height
+	}
+
+	var edits textEdits
+	var added []string
+	for _, p := range e.points {
+		if p.xNode != nil {
+			if p.moved {
+				edits.replace(p.xNode, e.format(p.x, width, refWidth))
+				edits.replace(p.yNode, e.format(p.y, height, refHeight))
+			}
+			continue
+		}
+
+		entry := fmt.Sprintf("{ code: %s, ", strconv.Quote(p.code))
+		if p.ctrl {
+			entry += "type: ctrl, "
+		}
+		entry += fmt.Sprintf("point: { x: %s, y: %s } }", e.format(p.x, width, refWidth), e.format(p.y, height, refHeight))
+		added = append(added, entry)
+	}
+
+	if len(added) > 0 {
+		if e.keys == nil && len(e.section) == 0 {
+			line := strings.Count(string(e.content), "\n") + 1
+			edits.insert(line, "keys:")
+			for _, entry := range added {
+				edits.insert(line, "  - "+entry)
+			}
+		} else if e.keys == nil {
+			// 在方向段的末尾添加 keys，缩进与段中的其他字段相同
+			if e.parent.Style&yaml.FlowStyle != 0 || len(e.parent.Content) == 0 {
+				return fmt.Errorf("line %d: cannot add keys to %s", e.parent.Line, e.section)
+			}
+			indent := strings.Repeat(" ", e.parent.Content[0].Column-1)
+			line := lastLine(e.parent) + 1
+			edits.insert(line, indent+"keys:")
+			for _, entry := range added {
+				edits.insert(line, indent+"  - "+entry)
+			}
+		} else if e.keys.Style&yaml.FlowStyle != 0 || len(e.keys.Content) == 0 {
+			return fmt.Errorf("line %d: cannot append to keys", e.keys.Line)
+		} else {
+			last := e.keys.Content[len(e.keys.Content)-1]
+			indent := strings.Repeat(" ", last.Column-3)
+			for _, entry := range added {
+				edits.insert(lastLine(last)+1, indent+"- "+entry)
+			}
+		}
+	}
+
+	if err := ioutil.WriteFile(e.file, edits.apply(e.content), 0644); err != nil {
+		return err
+	}
+	// 重新读取，之后的修改以新的文件内容为准。文件的变化会触发配置的重新加载
+	return e.load()
+}
+
+// 节点（包括所有子节点）所在的最后一行
+func lastLine(node *yaml.Node) int {
+	line := node.Line
+	for _, n := range node.Content {
+		if l := lastLine(n); l > line {
+			line = l
+		}
+	}
+	return line
+}
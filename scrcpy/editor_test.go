@@ -0,0 +1,98 @@
+package scrcpy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const editorConfig = `resolution: { width: 1080, height: 2248 }
+keys:
+  - { code: Q, point: { x: 100, y: 200 } }
+landscape:
+  keys:
+    - { code: Q, point: { x: 2000, y: 1000 } }
+    - code: E
+      macro:
+        - { point: { x: 10, y: 20 } }
+`
+
+func newTestEditor(t *testing.T, frame size) (*keyEditor, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "editor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "config.yaml")
+	if err = ioutil.WriteFile(path, []byte(editorConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := &keyEditor{screen: &screen{frameSize: frame}, path: func() string { return path }}
+	if err = e.load(); err != nil {
+		t.Fatal(err)
+	}
+	return e, path
+}
+
+func editorPoints(e *keyEditor) map[string][2]int32 {
+	points := make(map[string][2]int32)
+	for _, p := range e.points {
+		points[p.label] = [2]int32{p.x, p.y}
+	}
+	return points
+}
+
+func TestEditorPortrait(t *testing.T) {
+	e, _ := newTestEditor(t, size{width: 540, height: 1124})
+	if e.section != "" {
+		t.Errorf("section = %q, want keys", e.section)
+	}
+	points := editorPoints(e)
+	if len(points) != 1 || points["Q"] != [2]int32{50, 100} {
+		t.Errorf("points = %v, want Q at 50,100", points)
+	}
+}
+
+// 设备横屏时与 Config.ForFrame 一样编辑 landscape 段，使用旋转后的 resolution
+func TestEditorLandscapeSection(t *testing.T) {
+	e, path := newTestEditor(t, size{width: 1124, height: 540})
+	if e.section != "landscape" {
+		t.Fatalf("section = %q, want landscape", e.section)
+	}
+	points := editorPoints(e)
+	if len(points) != 2 || points["Q"] != [2]int32{1000, 500} || points["E#1"] != [2]int32{5, 10} {
+		t.Fatalf("points = %v", points)
+	}
+
+	for _, p := range e.points {
+		if p.label == "Q" {
+			p.x, p.y, p.moved = 562, 270, true
+		}
+	}
+	e.points = append(e.points, &editorPoint{code: "R", label: "R", x: 100, y: 50})
+	e.dirty = true
+	if err := e.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `resolution: { width: 1080, height: 2248 }
+keys:
+  - { code: Q, point: { x: 100, y: 200 } }
+landscape:
+  keys:
+    - { code: Q, point: { x: 1124, y: 540 } }
+    - code: E
+      macro:
+        - { point: { x: 10, y: 20 } }
+    - { code: "R", point: { x: 200, y: 100 } }
+`
+	if string(content) != want {
+		t.Errorf("saved:\n%s\nwant:\n%s", content, want)
+	}
+}
@@ -56,3 +56,33 @@ func (dl *defaultLooper) Loop() error {
 
 	return nil
 }
+
+// 投递自定义事件，带上窗口 id 以便多设备时分发给对应的 Session
+type eventPoster struct {
+	windowID uint32
+}
+
+func (p eventPoster) push(typ uint32, code int32) {
+	sdl.PushEvent(&sdl.UserEvent{Type: typ, WindowID: p.windowID, Code: code})
+}
+
+// 获取事件所属窗口，与窗口无关的事件（如 SDL_QUIT）返回 false
+func eventWindowID(event sdl.Event) (uint32, bool) {
+	switch e := event.(type) {
+	case *sdl.WindowEvent:
+		return e.WindowID, true
+	case *sdl.KeyboardEvent:
+		return e.WindowID, true
+	case *sdl.TextInputEvent:
+		return e.WindowID, true
+	case *sdl.MouseMotionEvent:
+		return e.WindowID, true
+	case *sdl.MouseButtonEvent:
+		return e.WindowID, true
+	case *sdl.MouseWheelEvent:
+		return e.WindowID, true
+	case *sdl.UserEvent:
+		return e.WindowID, true
+	}
+	return 0, false
+}
@@ -1,6 +1,8 @@
 package scrcpy
 
 import (
+	"path/filepath"
+
 	"github.com/ClarkGuan/go-sdl2/sdl"
 	"github.com/ClarkGuan/go-sdl2/ttf"
 )
@@ -23,6 +25,11 @@ func OpenFont(path string, size int) (*Font, error) {
 	}
 }
 
+// res 目录下自带的字体
+func openDefaultFont(size int) (*Font, error) {
+	return OpenFont(filepath.Join(sdl.GetBasePath(), "res", "YaHei.Consolas.1.12.ttf"), size)
+}
+
 func (f *Font) GetTextSurface(text string, color sdl.Color) (*sdl.Surface, error) {
 	return f.f.RenderUTF8Blended(text, color)
 }
@@ -43,8 +50,12 @@ func (tt *TextTexture) Update(renderer sdl.Renderer, f *Font, text string, color
 	}
 
 	tt.text = text
-	if len(tt.text) == 0 {
+	// 文字变化时释放旧的纹理
+	if tt.texture != 0 {
+		tt.texture.Destroy()
 		tt.texture = 0
+	}
+	if len(tt.text) == 0 {
 		return nil
 	}
 
@@ -52,6 +63,7 @@ func (tt *TextTexture) Update(renderer sdl.Renderer, f *Font, text string, color
 	if err != nil {
 		return err
 	}
+	defer surface.Free()
 	tt.texture, err = renderer.CreateTextureFromSurface(surface)
 	if err == nil {
 		tt.getTextureSize(src)
@@ -71,3 +83,56 @@ func (tt *TextTexture) getTextureSize(src *sdl.Rect) {
 		_, _, src.W, src.H, _ = tt.texture.Query()
 	}
 }
+
+// 在画面上显示一行提示信息，text 为空时不显示
+type messageRenderer struct {
+	font     *Font
+	texture  TextTexture
+	text     string
+	position sdl.Rect
+}
+
+func (m *messageRenderer) Init(r sdl.Renderer) {
+	var err error
+	if m.font == nil {
+		if m.font, err = openDefaultFont(35); err != nil {
+			panic(err)
+		}
+	}
+
+	m.position.X = 50
+	m.position.Y = 120
+}
+
+func (m *messageRenderer) Render(r sdl.Renderer) {
+	m.texture.Update(r, m.font, m.text, sdl.Color{R: 255, A: 255}, &m.position)
+	m.texture.Render(r, &m.position)
+}
+
+// 按文字缓存纹理，用于在画面上绘制大量的标签
+type labelCache struct {
+	font     *Font
+	textures map[string]*TextTexture
+}
+
+// 以 (cx, cy) 为中心绘制文字
+func (lc *labelCache) render(r sdl.Renderer, text string, color sdl.Color, cx, cy int32) {
+	if len(text) == 0 {
+		return
+	}
+	if lc.textures == nil {
+		lc.textures = make(map[string]*TextTexture)
+	}
+	tt := lc.textures[text]
+	if tt == nil {
+		tt = new(TextTexture)
+		lc.textures[text] = tt
+	}
+	var rect sdl.Rect
+	if err := tt.Update(r, lc.font, text, color, &rect); err != nil {
+		return
+	}
+	rect.X = cx - rect.W/2
+	rect.Y = cy - rect.H/2
+	tt.Render(r, &rect)
+}
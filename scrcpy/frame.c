@@ -1,33 +1,53 @@
+//go:build !nolibav
+// +build !nolibav
+
 #include <libavformat/avformat.h>
 #include <libavcodec/avcodec.h>
-#include <libavcodec/videotoolbox.h>
 #include <libavutil/avutil.h>
+#include <libavutil/hwcontext.h>
+#include <stdint.h>
 #include <stdio.h>
 
 #define BUFSIZE 0x10000
 
-int goReadPacket(void* p, void *buf, int size);
-void goPushFrame();
-void goNotifyStopped();
-AVFrame* goGetHardwareFrame();
-int goAvHwframeTransferData();
+int goReadPacket(int id, void *buf, int size);
+void goPushFrame(int id);
+void goOnPacket(int id, AVPacket *packet);
+AVFrame* goGetHardwareFrame(int id);
+int goAvHwframeTransferData(int id);
 
 static int read_packet(void *opaque, uint8_t *buf, int buf_size) {
-    return goReadPacket(opaque, (void *) buf, buf_size);
+    return goReadPacket((int) (intptr_t) opaque, (void *) buf, buf_size);
 }
 
 static enum AVPixelFormat get_hw_format(AVCodecContext *ctx,
                                         const enum AVPixelFormat *pix_fmts)
 {
     const enum AVPixelFormat *p;
+    // opaque 指向 run_decoder() 中选定的硬件像素格式
+    enum AVPixelFormat hw_pix_fmt = *(enum AVPixelFormat *) ctx->opaque;
 
     for (p = pix_fmts; *p != -1; p++) {
-        if (*p == AV_PIX_FMT_VIDEOTOOLBOX)
+        if (*p == hw_pix_fmt)
             return *p;
     }
 
-    fprintf(stderr, "Failed to get HW surface format\n");
-    return AV_PIX_FMT_NONE;
+    // 硬件不支持当前码流时退回软件解码
+    fprintf(stderr, "Failed to get HW surface format, fallback to software\n");
+    return avcodec_default_get_format(ctx, pix_fmts);
+}
+
+static enum AVPixelFormat find_hw_pix_fmt(AVCodec *codec, enum AVHWDeviceType type) {
+    for (int i = 0;; i++) {
+        const AVCodecHWConfig *config = avcodec_get_hw_config(codec, i);
+        if (!config) {
+            return AV_PIX_FMT_NONE;
+        }
+        if ((config->methods & AV_CODEC_HW_CONFIG_METHOD_HW_DEVICE_CTX) &&
+                config->device_type == type) {
+            return config->pix_fmt;
+        }
+    }
 }
 
 static int hw_decoder_init(AVCodecContext *ctx,
@@ -44,7 +64,10 @@ static int hw_decoder_init(AVCodecContext *ctx,
     return err;
 }
 
-int run_decoder() {
+// hw_type 为 AV_HWDEVICE_TYPE_NONE 时使用软件解码；
+// 硬件设备初始化失败时自动退回软件解码。
+// decode 为 0 时只读取码流（交给 goOnPacket 录像），不进行解码
+int run_decoder(int id, int hw_type, int decode) {
     AVCodec *codec = avcodec_find_decoder(AV_CODEC_ID_H264);
     if (!codec) {
         fprintf(stderr, "H.264 decoder not found\n");
@@ -57,14 +80,22 @@ int run_decoder() {
         goto run_end;
     }
 
-    enum AVHWDeviceType type = AV_HWDEVICE_TYPE_VIDEOTOOLBOX;
+    enum AVHWDeviceType type = (enum AVHWDeviceType) hw_type;
     AVBufferRef *hw_device_ctx = NULL;
-    if (hw_decoder_init(codec_ctx, &hw_device_ctx, type) < 0) {
-        fprintf(stderr, "Failed to create specified HW device\n");
-        goto run_end;
+    enum AVPixelFormat hw_pix_fmt = AV_PIX_FMT_NONE;
+    if (type != AV_HWDEVICE_TYPE_NONE) {
+        hw_pix_fmt = find_hw_pix_fmt(codec, type);
+        if (hw_pix_fmt == AV_PIX_FMT_NONE) {
+            fprintf(stderr, "Decoder does not support HW device %s, fallback to software\n",
+                    av_hwdevice_get_type_name(type));
+        } else if (hw_decoder_init(codec_ctx, &hw_device_ctx, type) < 0) {
+            fprintf(stderr, "Failed to create specified HW device %s, fallback to software\n",
+                    av_hwdevice_get_type_name(type));
+        } else {
+            codec_ctx->opaque = &hw_pix_fmt;
+            codec_ctx->get_format = get_hw_format;
+        }
     }
-    codec_ctx->hw_device_ctx = hw_device_ctx;
-    codec_ctx->get_format = get_hw_format;
 
     if (avcodec_open2(codec_ctx, codec, NULL) < 0) {
         fprintf(stderr, "Could not open H.264 codec\n");
@@ -83,7 +114,7 @@ int run_decoder() {
         goto run_finally_free_format_ctx;
     }
 
-    AVIOContext *avio_ctx = avio_alloc_context(buffer, BUFSIZE, 0, NULL, read_packet, NULL, NULL);
+    AVIOContext *avio_ctx = avio_alloc_context(buffer, BUFSIZE, 0, (void *) (intptr_t) id, read_packet, NULL, NULL);
     if (!avio_ctx) {
         fprintf(stderr, "Could not allocate avio context\n");
         // avformat_open_input takes ownership of 'buffer'
@@ -105,6 +136,15 @@ int run_decoder() {
     packet.size = 0;
 
     while (!av_read_frame(format_ctx, &packet)) {
+        goOnPacket(id, &packet);
+        if (!decode) {
+            av_packet_unref(&packet);
+            if (avio_ctx->eof_reached) {
+                break;
+            }
+            continue;
+        }
+
 // the new decoding/encoding API has been introduced by:
 // <http://git.videolan.org/?p=ffmpeg.git;a=commitdiff;h=7fc329e2dd6226dfecaa4a1d7adf353bf2773726>
 #if LIBAVCODEC_VERSION_INT >= AV_VERSION_INT(57, 37, 0)
@@ -113,12 +153,12 @@ int run_decoder() {
             fprintf(stderr, "Could not send video packet: %d\n", ret);
             goto run_quit;
         }
-        ret = avcodec_receive_frame(codec_ctx, goGetHardwareFrame());
+        ret = avcodec_receive_frame(codec_ctx, goGetHardwareFrame(id));
         if (!ret) {
-            ret = goAvHwframeTransferData();
+            ret = goAvHwframeTransferData(id);
             if (!ret) {
                 // a frame was received
-                goPushFrame();
+                goPushFrame(id);
             } else {
                 fprintf(stderr, "Could not receive video frame(1): %d\n", ret);
                 goto run_quit;
@@ -137,7 +177,7 @@ int run_decoder() {
                 goto run_quit;
             }
             if (got_picture) {
-                goPushFrame();
+                goPushFrame(id);
             }
             packet.size -= len;
             packet.data += len;
@@ -162,7 +202,7 @@ run_finally_close_codec:
     avcodec_close(codec_ctx);
 run_finally_free_codec_ctx:
     avcodec_free_context(&codec_ctx);
-    goNotifyStopped();
+    av_buffer_unref(&hw_device_ctx);
 run_end:
     return 0;
 }
\ No newline at end of file
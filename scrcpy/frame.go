@@ -1,33 +1,61 @@
+//go:build !nolibav
+// +build !nolibav
+
 package scrcpy
 
 //
 // #cgo LDFLAGS: -L/usr/local/lib -lavformat -lavcodec -lavutil
 // #cgo CFLAGS: -I/usr/local/include
 //
+// #include <stdlib.h>
 // #include <libavutil/avutil.h>
+// #include <libavutil/hwcontext.h>
 // #include <libavformat/avformat.h>
 //
-// int run_decoder();
+// int run_decoder(int id, int hw_type, int decode);
 //
 import "C"
 import (
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
 	"reflect"
 	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 
 	"github.com/ClarkGuan/go-sdl2/sdl"
 )
 
-const eventNewFrame = sdl.USEREVENT + 1
-const eventDecoderStopped = sdl.USEREVENT + 2
-
 var errAVAlloc = errors.New("av_frame_alloc() fail")
 
+// 将解码方式名称转换为 libav 的 AVHWDeviceType，AV_HWDEVICE_TYPE_NONE 表示软件解码
+func hwDeviceType(name string) (C.int, error) {
+	switch name {
+	case "", DecoderAuto:
+		if runtime.GOOS == "darwin" {
+			name = "videotoolbox"
+		} else {
+			return C.AV_HWDEVICE_TYPE_NONE, nil
+		}
+
+	case DecoderSoftware:
+		return C.AV_HWDEVICE_TYPE_NONE, nil
+	}
+
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	typ := C.av_hwdevice_find_type_by_name(cName)
+	if typ == C.AV_HWDEVICE_TYPE_NONE {
+		return C.AV_HWDEVICE_TYPE_NONE, fmt.Errorf("unknown decoder: %s", name)
+	}
+	return C.int(typ), nil
+}
+
 type avFrame uintptr
 
 func (af avFrame) width() int {
@@ -51,7 +79,7 @@ func (af avFrame) data(i int) (ret []byte) {
 	p := (*reflect.SliceHeader)(unsafe.Pointer(&ret))
 	p.Data = uintptr(unsafe.Pointer(tmp.data[C.int(i)]))
 	lineSize := af.lineSize(i)
-	if i == 1 {
+	if i > 0 {
 		p.Len = (lineSize * af.height()) >> 1
 	} else {
 		p.Len = lineSize * af.height()
@@ -65,17 +93,22 @@ func (af avFrame) lineSize(i int) int {
 	return int(tmp.linesize[C.int(i)])
 }
 
-func (af avFrame) copy(b []byte) []byte {
-	buf1, buf2 := af.data(0), af.data(1)
-	if buf1 == nil || buf2 == nil {
-		return b
-	}
-	if len(b) < len(buf1)+len(buf2) {
-		b = make([]byte, len(buf1)+len(buf2))
+func (af avFrame) format() C.int {
+	return C.int((*C.AVFrame)(unsafe.Pointer(af)).format)
+}
+
+// 对应的 SDL 纹理格式：硬件解码输出 NV12，软件解码输出 YUV420P
+func (af avFrame) pixelFormat() uint32 {
+	switch af.format() {
+	case C.AV_PIX_FMT_YUV420P, C.AV_PIX_FMT_YUVJ420P:
+		return sdl.PIXELFORMAT_IYUV
+	default:
+		return sdl.PIXELFORMAT_NV12
 	}
-	n := copy(b, buf1)
-	copy(b[n:], buf2)
-	return b
+}
+
+func (af avFrame) isHardware() bool {
+	return (*C.AVFrame)(unsafe.Pointer(af)).hw_frames_ctx != nil
 }
 
 func (af avFrame) isEmpty() bool {
@@ -150,53 +183,151 @@ func (f *frame) ConsumeRenderedFrame() avFrame {
 	return f.renderingFrame
 }
 
-type decoder struct {
-	*frame
+// 基于 libav 的解码器，C 代码通过 id 回调到对应的实例
+type libavDecoder struct {
+	frame
+	id        C.int
 	videoSock net.Conn
+	hwType    C.int
+	decode    bool
+	recorder  *recorder
+	listener  DecoderListener
+	lastSize  size
+	stopped   int32
+	done      chan struct{}
 }
 
-var gDecoder *decoder
+var libavDecoders = make(map[C.int]*libavDecoder)
+var libavDecoderNextId C.int
+var libavDecoderMutex sync.Mutex
+
+func newLibavDecoder(opt *decoderOption, sock net.Conn) (*libavDecoder, error) {
+	hwType, err := hwDeviceType(opt.name)
+	if err != nil {
+		return nil, err
+	}
 
-func getDecoder(f *frame, sock net.Conn) *decoder {
-	if gDecoder == nil {
-		gDecoder = &decoder{frame: f, videoSock: sock}
+	d := &libavDecoder{videoSock: sock, hwType: hwType, decode: !opt.noDisplay, listener: sdlDecoderListener{}}
+	if len(opt.recordPath) > 0 {
+		if d.recorder, err = newRecorder(opt.recordPath, opt.frameSize); err != nil {
+			return nil, err
+		}
 	}
-	return gDecoder
+	if err = d.frame.Init(); err != nil {
+		if d.recorder != nil {
+			d.recorder.Close()
+		}
+		return nil, err
+	}
+
+	libavDecoderMutex.Lock()
+	d.id = libavDecoderNextId
+	libavDecoderNextId++
+	libavDecoders[d.id] = d
+	libavDecoderMutex.Unlock()
+	return d, nil
+}
+
+func getLibavDecoder(id C.int) *libavDecoder {
+	libavDecoderMutex.Lock()
+	defer libavDecoderMutex.Unlock()
+	return libavDecoders[id]
 }
 
-func (d *decoder) Start() {
+func (d *libavDecoder) SetListener(l DecoderListener) {
+	d.listener = l
+}
+
+func (d *libavDecoder) Start() error {
+	d.done = make(chan struct{})
 	go func() {
 		runtime.LockOSThread()
 		defer runtime.UnlockOSThread()
-		C.run_decoder()
+		decode := 0
+		if d.decode {
+			decode = 1
+		}
+		C.run_decoder(d.id, d.hwType, C.int(decode))
+		// 视频流结束时立即完成录像文件
+		if d.recorder != nil {
+			d.recorder.Close()
+		}
+		d.listener.OnDecoderStopped()
+		close(d.done)
 	}()
+	return nil
+}
+
+func (d *libavDecoder) Stop() error {
+	if !atomic.CompareAndSwapInt32(&d.stopped, 0, 1) {
+		return nil
+	}
+
+	if d.done != nil {
+		// 让阻塞中的 Read 立即返回
+		d.videoSock.SetReadDeadline(time.Now())
+		<-d.done
+	}
+
+	libavDecoderMutex.Lock()
+	delete(libavDecoders, d.id)
+	libavDecoderMutex.Unlock()
+	if d.recorder != nil {
+		d.recorder.Close()
+	}
+	return d.frame.Close()
+}
+
+func (d *libavDecoder) ConsumeFrame(fn func(frame videoFrame) error) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return fn(d.ConsumeRenderedFrame())
 }
 
 //export goPushFrame
-func goPushFrame() {
-	d := gDecoder
+func goPushFrame(id C.int) {
+	d := getLibavDecoder(id)
+	newSize := size{width: uint16(d.decodingFrame.width()), height: uint16(d.decodingFrame.height())}
+	if newSize != d.lastSize {
+		old := d.lastSize
+		d.lastSize = newSize
+		d.listener.OnFrameSizeChanged(old, newSize)
+	}
+
 	previousFrameConsumed := d.OfferDecodeFrame()
 	if !previousFrameConsumed {
 		return
 	}
-	// 使用自定义 SDL 事件关联
-	sdl.PushEvent(&sdl.UserEvent{Type: eventNewFrame})
+	d.listener.OnNewFrame()
 }
 
-//export goNotifyStopped
-func goNotifyStopped() {
-	sdl.PushEvent(&sdl.UserEvent{Type: eventDecoderStopped})
+//export goOnPacket
+func goOnPacket(id C.int, packet *C.AVPacket) {
+	d := getLibavDecoder(id)
+	if d.recorder != nil {
+		if err := d.recorder.write(packet); err != nil && debugOpt.Error() {
+			log.Println(err)
+		}
+	}
 }
 
 //export goGetHardwareFrame
-func goGetHardwareFrame() *C.AVFrame {
-	d := gDecoder
+func goGetHardwareFrame(id C.int) *C.AVFrame {
+	d := getLibavDecoder(id)
 	return (*C.AVFrame)(unsafe.Pointer(d.hardwareFrame))
 }
 
 //export goAvHwframeTransferData
-func goAvHwframeTransferData() C.int {
-	d := gDecoder
+func goAvHwframeTransferData(id C.int) C.int {
+	d := getLibavDecoder(id)
+	// 软件解码得到的帧直接转移到 decodingFrame，无需拷贝
+	if !d.hardwareFrame.isHardware() {
+		dst := (*C.AVFrame)(unsafe.Pointer(d.decodingFrame))
+		C.av_frame_unref(dst)
+		C.av_frame_move_ref(dst, goGetHardwareFrame(id))
+		return 0
+	}
 	if !d.decodingFrame.isEmpty() {
 		if d.decodingFrame.width() != d.hardwareFrame.width() ||
 			(d.decodingFrame.height() != d.hardwareFrame.height()) {
@@ -205,12 +336,16 @@ func goAvHwframeTransferData() C.int {
 		}
 	}
 	return C.av_hwframe_transfer_data((*C.AVFrame)(unsafe.Pointer(d.decodingFrame)),
-		goGetHardwareFrame(), 0)
+		goGetHardwareFrame(id), 0)
 }
 
 //export goReadPacket
-func goReadPacket(_, buf unsafe.Pointer, bufSize C.int) C.int {
-	d := gDecoder
+func goReadPacket(id C.int, buf unsafe.Pointer, bufSize C.int) C.int {
+	d := getLibavDecoder(id)
+	if atomic.LoadInt32(&d.stopped) != 0 {
+		return 0
+	}
+
 	var buffer []byte
 	pb := (*reflect.SliceHeader)(unsafe.Pointer(&buffer))
 	pb.Data = uintptr(buf)
@@ -220,59 +355,11 @@ func goReadPacket(_, buf unsafe.Pointer, bufSize C.int) C.int {
 	if n, err := d.videoSock.Read(buffer); err == io.EOF {
 		return 0
 	} else if err != nil {
+		if atomic.LoadInt32(&d.stopped) != 0 {
+			return 0
+		}
 		return -1
 	} else {
 		return C.int(n)
 	}
 }
-
-func (s *screen) updateFrame(frames *frame) error {
-	frames.mutex.Lock()
-
-	frame := frames.ConsumeRenderedFrame()
-	if err := s.prepareForFrame(size{width: uint16(frame.width()),
-		height: uint16(frame.height())}); err != nil {
-		frames.mutex.Unlock()
-		return err
-	}
-
-	if err := s.updateTexture(frame); err != nil {
-		frames.mutex.Unlock()
-		return err
-	}
-	frames.mutex.Unlock()
-	s.render()
-	return nil
-}
-
-func (s *screen) updateTexture(frame avFrame) error {
-	s.bufs = frame.copy(s.bufs)
-	if s.bufs == nil {
-		return nil
-	}
-	return s.texture.Update(nil, s.bufs, frame.lineSize(0))
-}
-
-type frameHandler struct {
-	screen *screen
-	frames *frame
-}
-
-func (fh *frameHandler) HandleSdlEvent(event sdl.Event) (bool, error) {
-	switch event.GetType() {
-	case eventNewFrame:
-		if !fh.screen.hasFrame {
-			fh.screen.hasFrame = true
-			fh.screen.showWindow()
-		}
-		if err := fh.screen.updateFrame(fh.frames); err != nil {
-			return true, err
-		}
-
-	case eventDecoderStopped:
-		log.Println("Video decoder stopped")
-		return true, nil
-	}
-
-	return false, nil
-}
@@ -0,0 +1,225 @@
+package scrcpy
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"github.com/ClarkGuan/go-sdl2/sdl"
+)
+
+// 右摇杆推动时定时移动视角
+const eventGamepadTick = sdl.USEREVENT + 12
+
+const gamepadTickInterval = 16 * time.Millisecond
+
+// 扳机按下超过一半时视为按下，松开到 1/3 以下时视为松开，避免在阈值附近抖动
+const (
+	gamepadTriggerDown = math.MaxInt16 / 2
+	gamepadTriggerUp   = math.MaxInt16 / 3
+)
+
+// 打开所有手柄，并把手柄事件交给当前获得焦点的窗口对应的 Session。
+// 手柄事件不属于任何窗口，需要在 Session 之前处理
+type gamepadRouter struct {
+	sessions    []*Session
+	controllers map[sdl.JoystickID]sdl.GameController
+	focus       uint32
+}
+
+func newGamepadRouter(sessions []*Session) *gamepadRouter {
+	return &gamepadRouter{sessions: sessions, controllers: make(map[sdl.JoystickID]sdl.GameController)}
+}
+
+func (gr *gamepadRouter) HandleSdlEvent(event sdl.Event) (bool, error) {
+	switch e := event.(type) {
+	case *sdl.WindowEvent:
+		if e.Event == sdl.WINDOWEVENT_FOCUS_GAINED {
+			gr.focus = e.WindowID
+		}
+		return false, nil
+
+	case *sdl.ControllerDeviceEvent:
+		switch e.Type {
+		case sdl.CONTROLLERDEVICEADDED:
+			// 添加事件中的 Which 是设备序号，其他事件中是 instance id
+			c := sdl.GameControllerOpen(int(e.Which))
+			if c == 0 {
+				log.Printf("Could not open gamepad %d\n", e.Which)
+				return true, nil
+			}
+			gr.controllers[c.Joystick().InstanceID()] = c
+			log.Printf("Gamepad connected: %s\n", c.Name())
+
+		case sdl.CONTROLLERDEVICEREMOVED:
+			if c, ok := gr.controllers[e.Which]; ok {
+				log.Printf("Gamepad disconnected: %s\n", c.Name())
+				c.Close()
+				delete(gr.controllers, e.Which)
+			}
+			// 松开手柄按下的手指
+			if s := gr.focused(); s != nil {
+				s.handleGamepadRemoved()
+			}
+		}
+		return true, nil
+
+	case *sdl.ControllerButtonEvent, *sdl.ControllerAxisEvent:
+		if s := gr.focused(); s != nil {
+			return true, s.handleGamepadEvent(event)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// 只有一个窗口时不需要获得焦点
+func (gr *gamepadRouter) focused() *Session {
+	for _, s := range gr.sessions {
+		if !s.opt.NoDisplay && (len(gr.sessions) == 1 || s.poster.windowID == gr.focus) {
+			return s
+		}
+	}
+	return nil
+}
+
+func (gr *gamepadRouter) Close() {
+	for id, c := range gr.controllers {
+		c.Close()
+		delete(gr.controllers, id)
+	}
+}
+
+// 去掉死区后按曲线换算摇杆的偏移，返回 -1~1
+func stickValue(value int16, gc *GamepadConfig) float64 {
+	v := float64(value) / math.MaxInt16
+	if v < -1 {
+		v = -1
+	}
+	magnitude := math.Abs(v)
+	if magnitude <= gc.DeadZone {
+		return 0
+	}
+	return math.Copysign(math.Pow((magnitude-gc.DeadZone)/(1-gc.DeadZone), gc.Curve), v)
+}
+
+func (ch *controlHandler) handleGamepadButton(button int, down bool) (bool, error) {
+	opr := ch.gamepadKeyMap[button]
+	if opr == nil {
+		return true, nil
+	}
+
+	var p Point
+	switch o := opr.(type) {
+	case *Point:
+		p = *o
+	case *SPoint:
+		p = Point(*o)
+	case []*PointMacro:
+		if !down {
+			ca := newControllerAnimation(ch.controller, ch.fingers, o)
+			ca.start()
+		}
+		return true, nil
+	default:
+		return true, nil
+	}
+
+	id := ch.gamepadKeyState[button]
+	if down {
+		if id != nil {
+			return true, nil
+		}
+		if id = ch.fingers.GetId(); id == nil {
+			return true, nil
+		}
+		ch.gamepadKeyState[button] = id
+		return ch.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *id, p)
+	}
+	if id == nil {
+		return true, nil
+	}
+	b, e := ch.sendMouseEvent(AMOTION_EVENT_ACTION_UP, *id, p)
+	ch.fingers.Recycle(id)
+	ch.gamepadKeyState[button] = nil
+	return b, e
+}
+
+func (ch *controlHandler) handleGamepadAxis(axis uint8, value int16) (bool, error) {
+	switch axis {
+	case sdl.CONTROLLER_AXIS_LEFTX, sdl.CONTROLLER_AXIS_LEFTY:
+		ch.gamepadAxes[axis] = value
+		x := stickValue(ch.gamepadAxes[sdl.CONTROLLER_AXIS_LEFTX], &ch.gamepad)
+		y := stickValue(ch.gamepadAxes[sdl.CONTROLLER_AXIS_LEFTY], &ch.gamepad)
+		ch.directionController.setStick(x, y)
+		if x != 0 || y != 0 {
+			ch.directionController.Start()
+		}
+
+	case sdl.CONTROLLER_AXIS_RIGHTX, sdl.CONTROLLER_AXIS_RIGHTY:
+		ch.gamepadAxes[axis] = value
+		if !ch.gamepadTicking {
+			ch.handleGamepadTick()
+		}
+
+	case sdl.CONTROLLER_AXIS_TRIGGERLEFT, sdl.CONTROLLER_AXIS_TRIGGERRIGHT:
+		button := gamepadLeftTrigger
+		if axis == sdl.CONTROLLER_AXIS_TRIGGERRIGHT {
+			button = gamepadRightTrigger
+		}
+		pressed := ch.gamepadKeyState[button] != nil
+		if !pressed && value > gamepadTriggerDown {
+			return ch.handleGamepadButton(button, true)
+		} else if pressed && value < gamepadTriggerUp {
+			return ch.handleGamepadButton(button, false)
+		}
+	}
+	return true, nil
+}
+
+func (ch *controlHandler) gamepadVisionActive() bool {
+	return stickValue(ch.gamepadAxes[sdl.CONTROLLER_AXIS_RIGHTX], &ch.gamepad) != 0 ||
+		stickValue(ch.gamepadAxes[sdl.CONTROLLER_AXIS_RIGHTY], &ch.gamepad) != 0
+}
+
+// 右摇杆保持推动时按速度持续移动视角，不足一个像素的部分累积到下一次
+func (ch *controlHandler) handleGamepadTick() {
+	if !ch.gamepadVisionActive() {
+		ch.gamepadTicking = false
+		ch.gamepadRemainder = [2]float64{}
+		return
+	}
+	step := ch.gamepad.Speed * gamepadTickInterval.Seconds()
+	dx := stickValue(ch.gamepadAxes[sdl.CONTROLLER_AXIS_RIGHTX], &ch.gamepad)*step + ch.gamepadRemainder[0]
+	dy := stickValue(ch.gamepadAxes[sdl.CONTROLLER_AXIS_RIGHTY], &ch.gamepad)*step + ch.gamepadRemainder[1]
+	x, y := math.Trunc(dx), math.Trunc(dy)
+	ch.gamepadRemainder = [2]float64{dx - x, dy - y}
+	ch.visionController.visionControl2(int32(x), int32(y))
+	ch.gamepadTicking = true
+	ch.sendEventDelay(eventGamepadTick, gamepadTickInterval)
+}
+
+// 手柄断开时松开所有由手柄按下的手指
+func (ch *controlHandler) releaseGamepad() {
+	for button, id := range ch.gamepadKeyState {
+		if id == nil {
+			continue
+		}
+		if p, ok := ch.gamepadKeyMap[button].(*Point); ok {
+			ch.sendMouseEvent(AMOTION_EVENT_ACTION_UP, *id, *p)
+		} else if sp, ok := ch.gamepadKeyMap[button].(*SPoint); ok {
+			ch.sendMouseEvent(AMOTION_EVENT_ACTION_UP, *id, Point(*sp))
+		}
+		ch.fingers.Recycle(id)
+	}
+	ch.directionController.setStick(0, 0)
+	ch.clearGamepad()
+}
+
+func (ch *controlHandler) clearGamepad() {
+	ch.stopEvent(eventGamepadTick)
+	ch.gamepadTicking = false
+	ch.gamepadAxes = [sdl.CONTROLLER_AXIS_MAX]int16{}
+	ch.gamepadRemainder = [2]float64{}
+	ch.gamepadKeyState = make(map[int]*int)
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"log"
-	"path/filepath"
 	"time"
 
 	"github.com/ClarkGuan/go-sdl2/sdl"
@@ -23,31 +22,40 @@ const (
 const eventDirectionEvent = sdl.USEREVENT + 4
 const eventWheelEvent = sdl.USEREVENT + 5
 
-var mouseIntervalArray = []time.Duration{
+var defaultMouseIntervalArray = []time.Duration{
 	0,
 	30 * time.Millisecond,
 }
 
-var gunPressArray = []*GunPressConfig{
+var defaultGunPressArray = []*GunPressConfig{
 	nil,
 	{3, 28 * time.Millisecond},
 }
 
-func setConfigs(hit []time.Duration, stables []*GunPressConfig) {
-	if len(hit) > 0 {
-		mouseIntervalArray = mouseIntervalArray[:1]
-		mouseIntervalArray = append(mouseIntervalArray, hit...)
+// 第 0 项表示关闭连击
+func mouseIntervals(hits []time.Duration) []time.Duration {
+	if len(hits) > 0 {
+		return append([]time.Duration{0}, hits...)
 	}
+	return defaultMouseIntervalArray
+}
+
+// 第 0 项表示关闭自动压枪
+func gunPresses(stables []*GunPressConfig) []*GunPressConfig {
 	if len(stables) > 0 {
-		gunPressArray = gunPressArray[:1]
-		gunPressArray = append(gunPressArray, stables...)
+		return append([]*GunPressConfig{nil}, stables...)
 	}
+	return defaultGunPressArray
 }
 
 type controlHandler struct {
 	controller       Controller
+	fingers          *fingerState
+	poster           eventPoster
 	visionController *visionController
 	set              mouseEventSet
+	// 服务端报告的能力，为 nil 时不检查
+	filter *capabilityFilter
 
 	keyState map[int]*int
 	keyMap   map[int]UserOperation
@@ -58,11 +66,26 @@ type controlHandler struct {
 	mouseKeyState map[uint8]*int
 	mouseKeyMap   map[uint8]UserOperation
 
+	// 手柄按键及摇杆
+	gamepadKeyState  map[int]*int
+	gamepadKeyMap    map[int]UserOperation
+	gamepad          GamepadConfig
+	gamepadAxes      [sdl.CONTROLLER_AXIS_MAX]int16
+	gamepadRemainder [2]float64
+	gamepadTicking   bool
+
+	// 滚轮拖动，见 WheelDrag
+	wheelKeyMap       map[int]UserOperation
+	wheelDrag         *WheelDrag
+	wheelPosition     float64
 	wheelCachePointer Point
 
 	// 自动压枪处理
-	gunPress    int
-	gunPressOpr *gunPressOperation
+	gunPress      int
+	gunPressOpr   *gunPressOperation
+	gunPressArray []*GunPressConfig
+
+	mouseIntervalArray []time.Duration
 
 	directionController directionController
 	timer               map[uint32]*time.Timer
@@ -73,16 +96,28 @@ type controlHandler struct {
 	textTexture     *TextTexture
 	displayPosition sdl.Rect
 	textBuf         bytes.Buffer
+
+	screen *screen
+
+	// ctrl+k 切换按键提示
+	showHints  bool
+	hintLabels labelCache
+	// 在没有新的视频帧时重新绘制画面
+	refresh func()
 }
 
 func (ch *controlHandler) Init(r sdl.Renderer) {
 	var err error
 	if ch.font == nil {
-		if ch.font, err = OpenFont(filepath.Join(sdl.GetBasePath(), "res", "YaHei.Consolas.1.12.ttf"), 35); err != nil {
+		if ch.font, err = openDefaultFont(35); err != nil {
 			panic(err)
 		}
 	}
 
+	if ch.hintLabels.font, err = openDefaultFont(20); err != nil {
+		panic(err)
+	}
+
 	ch.textTexture = new(TextTexture)
 	ch.displayPosition.X = 50
 	ch.displayPosition.Y = 50
@@ -96,7 +131,7 @@ func (ch *controlHandler) Render(r sdl.Renderer) {
 		// ignore
 
 	default:
-		fmt.Fprintf(&ch.textBuf, "连击模式：%v  ", mouseIntervalArray[ch.doubleHit%len(mouseIntervalArray)])
+		fmt.Fprintf(&ch.textBuf, "连击模式：%v  ", ch.mouseIntervalArray[ch.doubleHit%len(ch.mouseIntervalArray)])
 	}
 
 	switch ch.gunPress {
@@ -104,47 +139,112 @@ func (ch *controlHandler) Render(r sdl.Renderer) {
 		// ignore
 
 	default:
-		fmt.Fprintf(&ch.textBuf, "自动压枪：%v", gunPressArray[ch.gunPress%len(gunPressArray)])
+		fmt.Fprintf(&ch.textBuf, "自动压枪：%v", ch.gunPressArray[ch.gunPress%len(ch.gunPressArray)])
 	}
 
 	ch.textTexture.Update(r, ch.font, ch.textBuf.String(), sdl.Color{}, &ch.displayPosition)
 	ch.textTexture.Render(r, &ch.displayPosition)
-}
 
-func newControlHandler(controller Controller,
-	keyMap, ctrlKeyMap map[int]UserOperation,
-	mouseKeyMap map[uint8]UserOperation) *controlHandler {
+	if ch.showHints {
+		ch.renderHints(r)
+	}
+}
 
-	ch := controlHandler{controller: controller}
+func newControlHandler(controller Controller, fingers *fingerState, poster eventPoster, opt *Option) *controlHandler {
+	ch := controlHandler{controller: controller, fingers: fingers, poster: poster}
 	controller.Register(&ch)
 	ch.keyState = make(map[int]*int)
 	ch.ctrlKeyState = make(map[int]*int)
 	ch.mouseKeyState = make(map[uint8]*int)
-	ch.keyMap = keyMap
-	ch.ctrlKeyMap = ctrlKeyMap
-	ch.mouseKeyMap = mouseKeyMap
-	ch.directionController.keyMap = keyMap
+	ch.gamepadKeyState = make(map[int]*int)
+	ch.directionController.fingers = fingers
+	ch.directionController.poster = poster
 	// 默认是正常模式
 	ch.doubleHit = 0
 	// 默认关闭自动压枪
 	ch.gunPress = 0
 
 	// 视角控制
-	ch.visionController = newVisionController(controller,
-		keyMap[VisionBoundTopLeft].(*Point),
-		keyMap[VisionBoundBottomRight].(*Point))
+	ch.visionController = newVisionController(controller, fingers, poster,
+		opt.MouseSensitive,
+		opt.KeyMap[VisionBoundTopLeft].(*Point),
+		opt.KeyMap[VisionBoundBottomRight].(*Point))
+
+	ch.applyConfig(&opt.Config)
 	return &ch
 }
 
+// 替换按键映射、视角范围、连击及压枪配置
+func (ch *controlHandler) applyConfig(cfg *Config) {
+	ch.keyMap = cfg.KeyMap
+	ch.ctrlKeyMap = cfg.CtrlKeyMap
+	ch.mouseKeyMap = cfg.MouseKeyMap
+	ch.gamepadKeyMap = cfg.GamepadKeyMap
+	ch.wheelKeyMap = cfg.WheelKeyMap
+	ch.gamepad = cfg.Gamepad
+	ch.directionController.keyMap = cfg.KeyMap
+	ch.directionController.middlePoint = nil
+	ch.visionController.setBounds(cfg.KeyMap[VisionBoundTopLeft].(*Point),
+		cfg.KeyMap[VisionBoundBottomRight].(*Point))
+
+	ch.mouseIntervalArray = mouseIntervals(cfg.Hits)
+	ch.gunPressArray = gunPresses(cfg.Stables)
+	ch.doubleHit %= len(ch.mouseIntervalArray)
+	ch.gunPress %= len(ch.gunPressArray)
+}
+
 func (ch *controlHandler) HandleControlEvent(c Controller, ent interface{}) interface{} {
 	if sme, ok := ent.(*singleMouseEvent); ok {
 		ch.set.accept(sme)
 		return &ch.set
 	}
+	if e, ok := ent.(resetTouchEvent); ok {
+		if e.release {
+			if err := ch.set.releaseAll(c.Writer(), c.Data()...); err != nil {
+				log.Println(err)
+			}
+		} else {
+			ch.set.reset()
+		}
+		return nil
+	}
 	return ent
 }
 
+// 重连之后设备上已经没有按下的手指，只清空本地记录的状态，不再发送 UP 事件
+func (ch *controlHandler) reset() {
+	ch.clearState()
+	ch.controller.PushEvent(resetTouchEvent{})
+}
+
+// 松开所有按下的手指
+func (ch *controlHandler) releaseAll() {
+	ch.clearState()
+	ch.controller.PushEvent(resetTouchEvent{release: true})
+}
+
+func (ch *controlHandler) clearState() {
+	ch.stopContinuousFire()
+	ch.stopGunPress()
+	for typ := range ch.timer {
+		ch.stopEvent(typ)
+	}
+	ch.visionController.reset()
+	ch.directionController.reset()
+	ch.keyState = make(map[int]*int)
+	ch.ctrlKeyState = make(map[int]*int)
+	ch.mouseKeyState = make(map[uint8]*int)
+	ch.clearGamepad()
+}
+
 func (ch *controlHandler) HandleSdlEvent(event sdl.Event) (bool, error) {
+	// 按键提示中需要高亮按下的按键
+	if ch.showHints {
+		switch event.GetType() {
+		case sdl.KEYDOWN, sdl.KEYUP, sdl.MOUSEBUTTONDOWN, sdl.MOUSEBUTTONUP:
+			defer ch.refresh()
+		}
+	}
 
 	// 处理视角 SDL 事件
 	if ch.visionController.handleSdlEvent(event.GetType()) {
@@ -155,12 +255,24 @@ func (ch *controlHandler) HandleSdlEvent(event sdl.Event) (bool, error) {
 	case eventDirectionEvent:
 		return true, ch.directionController.sendMouseEvent(ch.controller)
 
+	case eventGamepadTick:
+		ch.handleGamepadTick()
+		return true, nil
+
+	case sdl.CONTROLLERBUTTONDOWN, sdl.CONTROLLERBUTTONUP:
+		e := event.(*sdl.ControllerButtonEvent)
+		return ch.handleGamepadButton(int(e.Button), e.Type == sdl.CONTROLLERBUTTONDOWN)
+
+	case sdl.CONTROLLERAXISMOTION:
+		e := event.(*sdl.ControllerAxisEvent)
+		return ch.handleGamepadAxis(e.Axis, e.Value)
+
 	case eventWheelEvent:
 		var b bool
 		var e error
 		if ch.keyState[wheelKeyCode] != nil {
 			b, e = ch.sendMouseEvent(AMOTION_EVENT_ACTION_UP, *ch.keyState[wheelKeyCode], ch.wheelCachePointer)
-			fingers.Recycle(ch.keyState[wheelKeyCode])
+			ch.fingers.Recycle(ch.keyState[wheelKeyCode])
 			ch.keyState[wheelKeyCode] = nil
 		}
 		return b, e
@@ -196,7 +308,7 @@ func (ch *controlHandler) stopContinuousFire() {
 
 func (ch *controlHandler) startContinuousFire(interval time.Duration) {
 	if ch.continuousFire == nil {
-		ch.continuousFire = new(continuousFire)
+		ch.continuousFire = &continuousFire{fingers: ch.fingers}
 		ch.continuousFire.Point = *(ch.keyMap[FireKeyCode].(*Point))
 		ch.continuousFire.Start(ch.controller, interval)
 	} else {
@@ -215,34 +327,37 @@ func (ch *controlHandler) startGunPress(interval time.Duration, delta int) {
 	if ch.gunPress > 0 {
 		if ch.gunPressOpr == nil {
 			ch.gunPressOpr = new(gunPressOperation)
-			ch.gunPressOpr.Start(ch.visionController, *gunPressArray[ch.gunPress%len(gunPressArray)])
+			ch.gunPressOpr.Start(ch.visionController, *ch.gunPressArray[ch.gunPress%len(ch.gunPressArray)])
 		} else {
-			ch.gunPressOpr.SetValues(*gunPressArray[ch.gunPress%len(gunPressArray)])
+			ch.gunPressOpr.SetValues(*ch.gunPressArray[ch.gunPress%len(ch.gunPressArray)])
 		}
 	}
 }
 
 func (ch *controlHandler) startMainPointerMotion(x, y int32) {
 	if ch.keyState[mainPointerKeyCode] == nil {
-		ch.keyState[mainPointerKeyCode] = fingers.GetId()
-		ch.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *ch.keyState[mainPointerKeyCode], Point{uint16(x), uint16(y)})
+		id := ch.fingers.GetId()
+		if id == nil {
+			return
+		}
+		ch.keyState[mainPointerKeyCode] = id
+		ch.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *id, ch.screen.devicePoint(x, y))
 	} else {
 		panic("main pointer state error")
 	}
 }
 
 func (ch *controlHandler) continueMainPointerMotion(x, y int32) {
+	// 手指不够用时按下会被放弃，之后的移动也一起忽略
 	if ch.keyState[mainPointerKeyCode] != nil {
-		ch.sendMouseEvent(AMOTION_EVENT_ACTION_MOVE, *ch.keyState[mainPointerKeyCode], Point{uint16(x), uint16(y)})
-	} else {
-		panic("main pointer state error")
+		ch.sendMouseEvent(AMOTION_EVENT_ACTION_MOVE, *ch.keyState[mainPointerKeyCode], ch.screen.devicePoint(x, y))
 	}
 }
 
 func (ch *controlHandler) stopMainPointerMotion(x, y int32) {
 	if ch.keyState[mainPointerKeyCode] != nil {
-		ch.sendMouseEvent(AMOTION_EVENT_ACTION_UP, *ch.keyState[mainPointerKeyCode], Point{uint16(x), uint16(y)})
-		fingers.Recycle(ch.keyState[mainPointerKeyCode])
+		ch.sendMouseEvent(AMOTION_EVENT_ACTION_UP, *ch.keyState[mainPointerKeyCode], ch.screen.devicePoint(x, y))
+		ch.fingers.Recycle(ch.keyState[mainPointerKeyCode])
 		ch.keyState[mainPointerKeyCode] = nil
 	}
 }
@@ -283,18 +398,20 @@ func (ch *controlHandler) handleMouseButtonDown(event *sdl.MouseButtonEvent) (bo
 			switch ch.doubleHit {
 			case 0:
 				if ch.keyState[FireKeyCode] == nil {
-					ch.keyState[FireKeyCode] = fingers.GetId()
-					if debugOpt.Debug() {
-						log.Println("按下开火键")
+					if id := ch.fingers.GetId(); id != nil {
+						ch.keyState[FireKeyCode] = id
+						if debugOpt.Debug() {
+							log.Println("按下开火键")
+						}
+						ch.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *id, *(ch.keyMap[FireKeyCode].(*Point)))
 					}
-					ch.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *ch.keyState[FireKeyCode], *(ch.keyMap[FireKeyCode].(*Point)))
 				}
 				if debugOpt.Debug() {
 					log.Println("正常开火")
 				}
 
 			default:
-				ch.startContinuousFire(mouseIntervalArray[ch.doubleHit])
+				ch.startContinuousFire(ch.mouseIntervalArray[ch.doubleHit])
 			}
 
 			ch.startGunPress(30*time.Millisecond, 1)
@@ -304,8 +421,12 @@ func (ch *controlHandler) handleMouseButtonDown(event *sdl.MouseButtonEvent) (bo
 	} else if ch.mouseKeyMap[event.Button] != nil {
 		if p, ok := ch.mouseKeyMap[event.Button].(*Point); ok {
 			if ch.mouseKeyState[event.Button] == nil {
-				ch.mouseKeyState[event.Button] = fingers.GetId()
-				ch.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *ch.mouseKeyState[event.Button], *p)
+				id := ch.fingers.GetId()
+				if id == nil {
+					return true, nil
+				}
+				ch.mouseKeyState[event.Button] = id
+				ch.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *id, *p)
 			}
 		}
 	}
@@ -326,7 +447,7 @@ func (ch *controlHandler) handleMouseButtonUp(event *sdl.MouseButtonEvent) (bool
 		if sdl.GetRelativeMouseMode() {
 			if ch.keyState[FireKeyCode] != nil {
 				b, e := ch.sendMouseEvent(AMOTION_EVENT_ACTION_UP, *ch.keyState[FireKeyCode], *(ch.keyMap[FireKeyCode].(*Point)))
-				fingers.Recycle(ch.keyState[FireKeyCode])
+				ch.fingers.Recycle(ch.keyState[FireKeyCode])
 				ch.keyState[FireKeyCode] = nil
 				if debugOpt.Debug() {
 					log.Println("松开开火键")
@@ -338,11 +459,11 @@ func (ch *controlHandler) handleMouseButtonUp(event *sdl.MouseButtonEvent) (bool
 		if p, ok := ch.mouseKeyMap[event.Button].(*Point); ok {
 			if ch.mouseKeyState[event.Button] != nil {
 				ch.sendMouseEvent(AMOTION_EVENT_ACTION_UP, *ch.mouseKeyState[event.Button], *p)
-				fingers.Recycle(ch.mouseKeyState[event.Button])
+				ch.fingers.Recycle(ch.mouseKeyState[event.Button])
 				ch.mouseKeyState[event.Button] = nil
 			}
 		} else if pms, ok := ch.mouseKeyMap[event.Button].([]*PointMacro); ok {
-			ca := newControllerAnimation(ch.controller, pms)
+			ca := newControllerAnimation(ch.controller, ch.fingers, pms)
 			ca.start()
 		}
 	}
@@ -418,17 +539,30 @@ func (ch *controlHandler) handleKeyDown(event *sdl.KeyboardEvent) (bool, error)
 			}
 			ch.controller.PushEvent(&kce)
 			return true, nil
+
+		case sdl.K_k:
+			if event.Repeat == 0 {
+				ch.showHints = !ch.showHints
+				ch.refresh()
+			}
+			return true, nil
+
+		case sdl.K_v:
+			// 将电脑剪贴板的内容输入到设备上
+			if event.Repeat == 0 {
+				if text, err := sdl.GetClipboardText(); err != nil {
+					log.Println(err)
+				} else if err = pushText(ch.controller, text); err != nil {
+					log.Println(err)
+				}
+			}
+			return true, nil
 		}
 
 		keyCode := int(event.Keysym.Sym)
 		if ch.ctrlKeyMap[keyCode] != nil {
 			if p, ok := ch.ctrlKeyMap[keyCode].(*Point); ok {
-				if ch.ctrlKeyState[keyCode] == nil {
-					ch.ctrlKeyState[keyCode] = fingers.GetId()
-					return ch.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *ch.ctrlKeyState[keyCode], *p)
-				} else {
-					return ch.sendMouseEvent(AMOTION_EVENT_ACTION_MOVE, *ch.ctrlKeyState[keyCode], *p)
-				}
+				return ch.pressKey(ch.ctrlKeyState, keyCode, *p, event.Repeat > 0)
 			}
 		}
 	} else {
@@ -466,25 +600,31 @@ func (ch *controlHandler) handleKeyDown(event *sdl.KeyboardEvent) (bool, error)
 		keyCode := int(event.Keysym.Sym)
 		if ch.keyMap[keyCode] != nil {
 			if p, ok := ch.keyMap[keyCode].(*Point); ok {
-				if ch.keyState[keyCode] == nil {
-					ch.keyState[keyCode] = fingers.GetId()
-					return ch.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *ch.keyState[keyCode], *p)
-				} else {
-					return ch.sendMouseEvent(AMOTION_EVENT_ACTION_MOVE, *ch.keyState[keyCode], *p)
-				}
+				return ch.pressKey(ch.keyState, keyCode, *p, event.Repeat > 0)
 			} else if sp, ok := ch.keyMap[keyCode].(*SPoint); ok {
-				if ch.keyState[keyCode] == nil {
-					ch.keyState[keyCode] = fingers.GetId()
-					return ch.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *ch.keyState[keyCode], Point(*sp))
-				} else {
-					return ch.sendMouseEvent(AMOTION_EVENT_ACTION_MOVE, *ch.keyState[keyCode], Point(*sp))
-				}
+				return ch.pressKey(ch.keyState, keyCode, Point(*sp), event.Repeat > 0)
 			}
 		}
 	}
 	return true, nil
 }
 
+// 按住不放时发送 MOVE。按下时手指不够用就放弃这次按键，按住期间不再重试
+func (ch *controlHandler) pressKey(state map[int]*int, keyCode int, p Point, repeat bool) (bool, error) {
+	if id := state[keyCode]; id != nil {
+		return ch.sendMouseEvent(AMOTION_EVENT_ACTION_MOVE, *id, p)
+	}
+	if repeat {
+		return true, nil
+	}
+	id := ch.fingers.GetId()
+	if id == nil {
+		return true, nil
+	}
+	state[keyCode] = id
+	return ch.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *id, p)
+}
+
 func (ch *controlHandler) handleKeyUp(event *sdl.KeyboardEvent) (bool, error) {
 	alt := event.Keysym.Mod&(sdl.KMOD_RALT|sdl.KMOD_LALT) != 0
 	if alt {
@@ -554,17 +694,24 @@ func (ch *controlHandler) handleKeyUp(event *sdl.KeyboardEvent) (bool, error) {
 		case sdl.K_x:
 			sdl.SetRelativeMouseMode(!sdl.GetRelativeMouseMode())
 			return true, nil
+
+		case sdl.K_v, sdl.K_k:
+			return true, nil
 		}
 
 		keyCode := int(event.Keysym.Sym)
 		if ch.ctrlKeyMap[keyCode] != nil {
 			if p, ok := ch.ctrlKeyMap[keyCode].(*Point); ok {
+				// 按下时手指已用完，没有需要松开的手指
+				if ch.ctrlKeyState[keyCode] == nil {
+					return true, nil
+				}
 				ch.sendMouseEvent(AMOTION_EVENT_ACTION_UP, *ch.ctrlKeyState[keyCode], *p)
-				fingers.Recycle(ch.ctrlKeyState[keyCode])
+				ch.fingers.Recycle(ch.ctrlKeyState[keyCode])
 				ch.ctrlKeyState[keyCode] = nil
 				return true, nil
 			} else if pms, ok := ch.ctrlKeyMap[keyCode].([]*PointMacro); ok {
-				ca := newControllerAnimation(ch.controller, pms)
+				ca := newControllerAnimation(ch.controller, ch.fingers, pms)
 				ca.start()
 				return true, nil
 			}
@@ -572,7 +719,7 @@ func (ch *controlHandler) handleKeyUp(event *sdl.KeyboardEvent) (bool, error) {
 	} else {
 		n := event.Keysym.Sym - sdl.K_F2
 		if n >= 0 && n <= (sdl.K_F12-sdl.K_F2) {
-			ch.gunPress = int(n) % len(gunPressArray)
+			ch.gunPress = int(n) % len(ch.gunPressArray)
 			return true, nil
 		}
 
@@ -583,7 +730,7 @@ func (ch *controlHandler) handleKeyUp(event *sdl.KeyboardEvent) (bool, error) {
 			return true, nil
 
 		case sdl.K_F1:
-			ch.doubleHit = (ch.doubleHit + 1) % len(mouseIntervalArray)
+			ch.doubleHit = (ch.doubleHit + 1) % len(ch.mouseIntervalArray)
 			return true, nil
 
 		case sdl.K_w:
@@ -616,7 +763,7 @@ func (ch *controlHandler) handleKeyUp(event *sdl.KeyboardEvent) (bool, error) {
 			if p, ok := ch.keyMap[keyCode].(*Point); ok {
 				if ch.keyState[keyCode] != nil {
 					b, e := ch.sendMouseEvent(AMOTION_EVENT_ACTION_UP, *ch.keyState[keyCode], *p)
-					fingers.Recycle(ch.keyState[keyCode])
+					ch.fingers.Recycle(ch.keyState[keyCode])
 					ch.keyState[keyCode] = nil
 					return b, e
 				}
@@ -624,12 +771,12 @@ func (ch *controlHandler) handleKeyUp(event *sdl.KeyboardEvent) (bool, error) {
 				sdl.SetRelativeMouseMode(!sdl.GetRelativeMouseMode())
 				if ch.keyState[keyCode] != nil {
 					b, e := ch.sendMouseEvent(AMOTION_EVENT_ACTION_UP, *ch.keyState[keyCode], Point(*sp))
-					fingers.Recycle(ch.keyState[keyCode])
+					ch.fingers.Recycle(ch.keyState[keyCode])
 					ch.keyState[keyCode] = nil
 					return b, e
 				}
 			} else if pms, ok := ch.keyMap[keyCode].([]*PointMacro); ok {
-				ca := newControllerAnimation(ch.controller, pms)
+				ca := newControllerAnimation(ch.controller, ch.fingers, pms)
 				ca.start()
 			}
 		}
@@ -642,24 +789,22 @@ func (ch *controlHandler) handleMouseWheelMotion(event *sdl.MouseWheelEvent) (bo
 	if debugOpt.Debug() {
 		log.Printf("x: %d, y: %d, direction: %d\n", event.X, event.Y, event.Direction)
 	}
-	if ch.keyState[wheelKeyCode] == nil {
-		ch.keyState[wheelKeyCode] = fingers.GetId()
-		ch.wheelCachePointer = *(ch.keyMap[sdl.K_g].(*Point))
-		ch.sendEventDelay(eventWheelEvent, 150*time.Millisecond)
-		return ch.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *ch.keyState[wheelKeyCode], ch.wheelCachePointer)
-	} else {
-		deltaY := event.Y * 10
-		tmp := int32(ch.wheelCachePointer.Y) + deltaY
-		if tmp < 0 {
-			tmp = 0
-		} else if tmp > 800 {
-			tmp = 800
+	x, y := event.X, event.Y
+	if event.Direction == sdl.MOUSEWHEEL_FLIPPED {
+		x, y = -x, -y
+	}
+
+	// 自由鼠标模式下滚动鼠标指针所在位置的列表。旧版本的服务端无法注入滚动事件，不发送
+	if !sdl.GetRelativeMouseMode() {
+		if ch.filter != nil && !ch.filter.allow(CONTROL_EVENT_TYPE_SCROLL) {
+			return true, nil
 		}
-		ch.wheelCachePointer.Y = uint16(tmp)
-		ch.sendEventDelay(eventWheelEvent, 150*time.Millisecond)
-		return ch.sendMouseEvent(AMOTION_EVENT_ACTION_MOVE, *ch.keyState[wheelKeyCode], ch.wheelCachePointer)
+		mx, my, _ := sdl.GetMouseState()
+		return true, ch.controller.PushEvent(&scrollEvent{Point: ch.screen.devicePoint(mx, my), hScroll: x, vScroll: y})
 	}
-	return true, nil
+
+	// 游戏模式下按住配置的点拖动
+	return ch.handleWheelDrag(x, y)
 }
 
 func (ch *controlHandler) sendMouseEvent(action androidMotionEventAction, id int, p Point) (bool, error) {
@@ -678,7 +823,7 @@ func (ch *controlHandler) sendEventDelay(typ uint32, duration time.Duration) {
 		ch.timer[typ].Reset(duration)
 	} else {
 		ch.timer[typ] = time.AfterFunc(duration, func() {
-			sdl.PushEvent(&sdl.UserEvent{Type: typ})
+			ch.poster.push(typ, 0)
 		})
 	}
 }
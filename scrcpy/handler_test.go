@@ -0,0 +1,65 @@
+package scrcpy
+
+import (
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/ClarkGuan/go-sdl2/sdl"
+)
+
+// 记录推送的事件，不做任何处理
+type recordController struct {
+	events []interface{}
+	mutex  sync.Mutex
+}
+
+func (rc *recordController) Start()                       {}
+func (rc *recordController) Stop() error                  { return nil }
+func (rc *recordController) Register(ControlEventHandler) {}
+func (rc *recordController) Remove(ControlEventHandler)   {}
+func (rc *recordController) Writer() io.Writer            { return ioutil.Discard }
+func (rc *recordController) Data() []interface{}          { return nil }
+func (rc *recordController) PushEvent(event interface{}) error {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.events = append(rc.events, event)
+	return nil
+}
+
+func TestCtrlKeyReleaseWithoutFinger(t *testing.T) {
+	var fingers fingerState
+	fingers.configure(1, FingerReject)
+	held := fingers.GetId()
+
+	rc := &recordController{}
+	ch := &controlHandler{controller: rc, fingers: &fingers,
+		keyState: make(map[int]*int), ctrlKeyState: make(map[int]*int),
+		ctrlKeyMap: map[int]UserOperation{int(sdl.K_q): &Point{100, 200}}}
+	key := sdl.Keysym{Sym: sdl.K_q, Mod: sdl.KMOD_LCTRL}
+
+	if _, err := ch.handleKeyDown(&sdl.KeyboardEvent{Keysym: key}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ch.handleKeyUp(&sdl.KeyboardEvent{Keysym: key}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rc.events) != 0 {
+		t.Fatalf("sent %d events without a finger", len(rc.events))
+	}
+
+	// 手指空出来之后可以正常按下、松开
+	fingers.Recycle(held)
+	ch.handleKeyDown(&sdl.KeyboardEvent{Keysym: key})
+	ch.handleKeyUp(&sdl.KeyboardEvent{Keysym: key})
+	if len(rc.events) != 2 {
+		t.Fatalf("got %d events, want DOWN and UP", len(rc.events))
+	}
+	if up := rc.events[1].(*singleMouseEvent); up.action != AMOTION_EVENT_ACTION_UP {
+		t.Errorf("second event action = %v, want UP", up.action)
+	}
+	if fingers.GetId() == nil {
+		t.Error("finger not recycled after release")
+	}
+}
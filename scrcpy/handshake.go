@@ -0,0 +1,158 @@
+package scrcpy
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+)
+
+// 客户端使用的协议版本，作为 app_process 的参数传给服务端。
+// 新版本的服务端在设备信息之前先发送握手头：magic（4 字节）+ 版本（2 字节）+ 能力长度（2 字节）+ 能力，
+// 能力只会在末尾追加，不认识的部分按长度跳过
+const (
+	protocolVersion = 1
+	handshakeMagic  = "SCGO"
+)
+
+// 设备支持的编码格式
+const (
+	codecH264 uint32 = 1 << iota
+	codecH265
+)
+
+// 服务端在握手时报告的能力
+type capabilities struct {
+	version     int // 0 表示旧版本的服务端，没有握手头
+	maxPointers int
+	eventTypes  uint32
+	codecs      uint32
+}
+
+// 旧版本的服务端不会报告能力：滚动事件无法注入，也没有设备消息
+var legacyCapabilities = capabilities{
+	maxPointers: 16,
+	eventTypes: 1<<CONTROL_EVENT_TYPE_KEYCODE | 1<<CONTROL_EVENT_TYPE_TEXT |
+		1<<CONTROL_EVENT_TYPE_MOUSE | 1<<CONTROL_EVENT_TYPE_COMMAND,
+	codecs: codecH264,
+}
+
+var eventTypeNames = []string{"keycode", "text", "mouse", "scroll", "command"}
+
+func (t controlEventType) String() string {
+	if int(t) < len(eventTypeNames) {
+		return eventTypeNames[t]
+	}
+	return fmt.Sprintf("type %d", t)
+}
+
+func (c *capabilities) supports(t controlEventType) bool {
+	return c.eventTypes&(1<<t) != 0
+}
+
+func (c capabilities) String() string {
+	var events []string
+	for t := range eventTypeNames {
+		if c.supports(controlEventType(t)) {
+			events = append(events, eventTypeNames[t])
+		}
+	}
+	var codecs []string
+	if c.codecs&codecH264 != 0 {
+		codecs = append(codecs, "h264")
+	}
+	if c.codecs&codecH265 != 0 {
+		codecs = append(codecs, "h265")
+	}
+	return fmt.Sprintf("protocol %d, %d pointers, events [%s], codecs [%s]",
+		c.version, c.maxPointers, strings.Join(events, " "), strings.Join(codecs, " "))
+}
+
+// magic 之后的部分
+func readCapabilities(r io.Reader) (caps capabilities, err error) {
+	header := make([]byte, 4)
+	if _, err = io.ReadFull(r, header); err != nil {
+		return
+	}
+	caps.version = int(binary.BigEndian.Uint16(header))
+	buf := make([]byte, binary.BigEndian.Uint16(header[2:]))
+	if _, err = io.ReadFull(r, buf); err != nil {
+		return
+	}
+	if len(buf) < 9 {
+		return caps, fmt.Errorf("handshake: capabilities too short (%d bytes)", len(buf))
+	}
+	caps.maxPointers = int(buf[0])
+	caps.eventTypes = binary.BigEndian.Uint32(buf[1:])
+	caps.codecs = binary.BigEndian.Uint32(buf[5:])
+	return
+}
+
+// 比客户端新的服务端可能改变了事件格式，直接拒绝；更旧的按报告的能力降级
+func (svr *server) checkCapabilities(caps capabilities) error {
+	if caps.version > protocolVersion {
+		return fmt.Errorf("server protocol %d is newer than this client (protocol %d); %s",
+			caps.version, protocolVersion, svr.jarHint())
+	}
+	if caps.codecs&codecH264 == 0 {
+		return errors.New("the device has no H.264 encoder")
+	}
+	return nil
+}
+
+// 握手不匹配时提示正在使用的 server.jar。res 中的 jar 也可能没有随 server/ 的源码重新构建，
+// 所以提示重新构建，而不是换成随客户端发布的 jar
+func (svr *server) jarHint() string {
+	if svr.overTcp {
+		return "the server was started on the device by res/start.sh, push a scrcpy-server.jar built by server/build_server.sh and restart it"
+	}
+	return fmt.Sprintf("server jar in use: %s (rebuild it from the server sources with server/build_server.sh, or point SCRCPY_SERVER_PATH at an up-to-date one)",
+		svr.getLocalServerPath())
+}
+
+// 丢弃服务端不支持的控制事件，每种类型只提示一次。
+// 最后注册，在其他 handler 合并出最终的事件之后检查
+type capabilityFilter struct {
+	caps   capabilities
+	warned map[controlEventType]bool
+	mutex  sync.Mutex
+}
+
+func (f *capabilityFilter) set(caps capabilities) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.caps = caps
+	f.warned = nil
+}
+
+func (f *capabilityFilter) HandleControlEvent(c Controller, ent interface{}) interface{} {
+	ce, ok := ent.(ControlEvent)
+	if !ok {
+		return ent
+	}
+
+	if !f.allow(ce.EventType()) {
+		return nil
+	}
+	return ent
+}
+
+// 服务端不支持时第一次调用会在日志中提示
+func (f *capabilityFilter) allow(t controlEventType) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.caps.supports(t) {
+		return true
+	}
+	if !f.warned[t] {
+		if f.warned == nil {
+			f.warned = make(map[controlEventType]bool)
+		}
+		f.warned[t] = true
+		log.Printf("the device server does not support %v events, dropped (%v)\n", t, f.caps)
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package scrcpy
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func handshakeHeader(version int, caps ...byte) []byte {
+	buf := []byte(handshakeMagic)
+	buf = append(buf, byte(version>>8), byte(version), 0, byte(len(caps)))
+	return append(buf, caps...)
+}
+
+func deviceInfo(name string, width, height uint16) []byte {
+	buf := make([]byte, deviceNameLength+4)
+	copy(buf, name)
+	binary.BigEndian.PutUint16(buf[deviceNameLength:], width)
+	binary.BigEndian.PutUint16(buf[deviceNameLength+2:], height)
+	return buf
+}
+
+func readDeviceInfoFrom(data []byte) (string, size, capabilities, error) {
+	client, device := net.Pipe()
+	defer client.Close()
+	go func() {
+		device.Write(data)
+		device.Close()
+	}()
+	svr := server{deviceConn: client}
+	svr.overTcp = true
+	return svr.ReadDeviceInfo()
+}
+
+func TestReadDeviceInfoVersioned(t *testing.T) {
+	caps := []byte{16, 0, 0, 0, 0x1f, 0, 0, 0, 3}
+	// 更新的服务端在末尾追加的能力会被跳过
+	caps = append(caps, 0xff, 0xff)
+	data := append(handshakeHeader(1, caps...), deviceInfo("Pixel", 1080, 2340)...)
+
+	name, sz, got, err := readDeviceInfoFrom(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(name, "Pixel") || sz != (size{width: 1080, height: 2340}) {
+		t.Errorf("got %q %v", name, sz)
+	}
+	want := capabilities{version: 1, maxPointers: 16, eventTypes: 0x1f, codecs: codecH264 | codecH265}
+	if got != want {
+		t.Errorf("caps = %v, want %v", got, want)
+	}
+	if !got.supports(CONTROL_EVENT_TYPE_SCROLL) {
+		t.Error("scroll should be supported")
+	}
+}
+
+func TestReadDeviceInfoLegacy(t *testing.T) {
+	name, sz, caps, err := readDeviceInfoFrom(deviceInfo("MI 8", 1080, 2248))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(name, "MI 8") || sz != (size{width: 1080, height: 2248}) {
+		t.Errorf("got %q %v", name, sz)
+	}
+	if caps != legacyCapabilities || caps.supports(CONTROL_EVENT_TYPE_SCROLL) {
+		t.Errorf("caps = %v, want legacy without scroll", caps)
+	}
+}
+
+func TestReadDeviceInfoMismatch(t *testing.T) {
+	data := append(handshakeHeader(protocolVersion+1, 16, 0, 0, 0, 0x1f, 0, 0, 0, 1), deviceInfo("x", 1, 1)...)
+	if _, _, _, err := readDeviceInfoFrom(data); err == nil || !strings.Contains(err.Error(), "start.sh") {
+		t.Errorf("newer server: err = %v, want a hint about the server in use", err)
+	}
+
+	data = append(handshakeHeader(1, 16, 0, 0, 0, 0x1f, 0, 0, 0, 2), deviceInfo("x", 1, 1)...)
+	if _, _, _, err := readDeviceInfoFrom(data); err == nil {
+		t.Error("no H.264 encoder: want error")
+	}
+
+	data = handshakeHeader(1, 16, 0, 0)
+	if _, _, _, err := readDeviceInfoFrom(data); err == nil {
+		t.Error("short capabilities: want error")
+	}
+}
+
+func TestCapabilityFilter(t *testing.T) {
+	var f capabilityFilter
+	f.set(legacyCapabilities)
+
+	if f.HandleControlEvent(nil, &scrollEvent{}) != nil {
+		t.Error("scroll event should be dropped for a legacy server")
+	}
+	ev := &textEvent{}
+	if f.HandleControlEvent(nil, ev) != ev {
+		t.Error("text event should pass")
+	}
+	// 不是控制事件的交给默认处理
+	if f.HandleControlEvent(nil, resetTouchEvent{}) != (resetTouchEvent{}) {
+		t.Error("other values should pass")
+	}
+}
+
+// 模拟 start.sh 启动的服务端：建立视频连接并发送 data，versioned 时再建立消息连接
+func connectFakeServer(t *testing.T, data []byte, versioned bool) (*server, capabilities, time.Duration) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := make(chan net.Conn, 2)
+	go func() {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			return
+		}
+		conns <- conn
+		conn.Write(data)
+		if versioned {
+			if conn, err = net.Dial("tcp", listener.Addr().String()); err == nil {
+				conns <- conn
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		for {
+			select {
+			case conn := <-conns:
+				conn.Close()
+			default:
+				return
+			}
+		}
+	})
+
+	svr := &server{listener: listener}
+	svr.overTcp = true
+	start := time.Now()
+	_, _, caps, err := svr.ConnectTo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { svr.Close() })
+	return svr, caps, time.Since(start)
+}
+
+func TestConnectMessageAfterHandshake(t *testing.T) {
+	data := append(handshakeHeader(1, 16, 0, 0, 0, 0x1f, 0, 0, 0, 1), deviceInfo("x", 1, 1)...)
+	svr, caps, _ := connectFakeServer(t, data, true)
+	if caps.version != 1 || svr.messageConn == nil {
+		t.Errorf("version %d, message connection %v: want both", caps.version, svr.messageConn)
+	}
+}
+
+// 旧版本的服务端不会建立消息连接，不需要等待 messageConnTimeout
+func TestConnectLegacyWithoutMessages(t *testing.T) {
+	svr, _, d := connectFakeServer(t, deviceInfo("x", 1, 1), false)
+	if svr.messageConn != nil {
+		t.Error("unexpected message connection")
+	}
+	if d >= messageConnTimeout/2 {
+		t.Errorf("connecting took %v", d)
+	}
+}
@@ -0,0 +1,121 @@
+package scrcpy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ClarkGuan/go-sdl2/sdl"
+)
+
+var (
+	hintColor        = sdl.Color{R: 0, G: 120, B: 255, A: 140}
+	hintPressedColor = sdl.Color{R: 255, G: 40, B: 40, A: 200}
+	hintMacroColor   = sdl.Color{R: 255, G: 140, B: 0, A: 140}
+	hintTextColor    = sdl.Color{R: 255, G: 255, B: 255, A: 255}
+)
+
+func keyCodeName(keyCode int) string {
+	for name, code := range KeyCodeConstMap {
+		if code == keyCode {
+			return strings.TrimPrefix(name, "SCRCPY_")
+		}
+	}
+	return sdl.GetKeyName(sdl.Keycode(keyCode))
+}
+
+func mouseButtonName(button uint8) string {
+	for name, b := range MouseButtonMap {
+		if b == button {
+			return strings.Replace(name, "BUTTON_", "MOUSE_", 1)
+		}
+	}
+	return fmt.Sprintf("MOUSE_%d", button)
+}
+
+func gamepadButtonName(button int) string {
+	for name, b := range GamepadButtonMap {
+		if b == button {
+			return strings.Replace(name, "GAMEPAD_", "PAD_", 1)
+		}
+	}
+	return fmt.Sprintf("PAD_%d", button)
+}
+
+// ctrl+k 打开的按键提示：在每个按键对应的位置显示按键名称，按下的按键高亮，
+// 宏显示为按顺序编号并连线的各个点。坐标即设备画面坐标（渲染器的视口与缩放与画面一致）
+func (ch *controlHandler) renderHints(r sdl.Renderer) {
+	radius := int32(ch.screen.frameSize.height) / 50
+
+	r.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	defer r.SetDrawBlendMode(sdl.BLENDMODE_NONE)
+
+	// 视角控制区域
+	topLeft, ok1 := ch.keyMap[VisionBoundTopLeft].(*Point)
+	bottomRight, ok2 := ch.keyMap[VisionBoundBottomRight].(*Point)
+	if ok1 && ok2 {
+		setDrawColor(r, hintColor)
+		r.DrawRect(&sdl.Rect{X: int32(topLeft.X), Y: int32(topLeft.Y),
+			W: int32(bottomRight.X) - int32(topLeft.X), H: int32(bottomRight.Y) - int32(topLeft.Y)})
+		ch.hintLabels.render(r, "VISION", hintTextColor, int32(topLeft.X)+60, int32(topLeft.Y)+20)
+	}
+
+	for keyCode, opr := range ch.keyMap {
+		if keyCode == VisionBoundTopLeft || keyCode == VisionBoundBottomRight {
+			continue
+		}
+		ch.renderHint(r, keyCodeName(keyCode), opr, ch.keyState[keyCode] != nil, radius)
+	}
+	for keyCode, opr := range ch.ctrlKeyMap {
+		ch.renderHint(r, "Ctrl+"+keyCodeName(keyCode), opr, ch.ctrlKeyState[keyCode] != nil, radius)
+	}
+	for button, opr := range ch.mouseKeyMap {
+		ch.renderHint(r, mouseButtonName(button), opr, ch.mouseKeyState[button] != nil, radius)
+	}
+	for button, opr := range ch.gamepadKeyMap {
+		ch.renderHint(r, gamepadButtonName(button), opr, ch.gamepadKeyState[button] != nil, radius)
+	}
+	for mod, opr := range ch.wheelKeyMap {
+		pressed := ch.keyState[wheelKeyCode] != nil && ch.wheelDrag == opr
+		ch.renderHint(r, wheelCodeName(mod), opr, pressed, radius)
+	}
+}
+
+func (ch *controlHandler) renderHint(r sdl.Renderer, name string, opr UserOperation, pressed bool, radius int32) {
+	switch o := opr.(type) {
+	case *SPoint:
+		ch.renderHint(r, name, (*Point)(o), pressed, radius)
+
+	case *Point:
+		if pressed {
+			setDrawColor(r, hintPressedColor)
+		} else {
+			setDrawColor(r, hintColor)
+		}
+		fillCircle(r, int32(o.X), int32(o.Y), radius)
+		ch.hintLabels.render(r, name, hintTextColor, int32(o.X), int32(o.Y))
+
+	case *WheelDrag:
+		// 拖动范围画成一条线
+		setDrawColor(r, hintMacroColor)
+		min, max := o.bounds(ch.screen.frameSize)
+		from, to := o.at(min), o.at(max)
+		r.DrawLine(int32(from.X), int32(from.Y), int32(to.X), int32(to.Y))
+		ch.renderHint(r, name, &o.Point, pressed, radius)
+
+	case []*PointMacro:
+		setDrawColor(r, hintMacroColor)
+		for i := 1; i < len(o); i++ {
+			r.DrawLine(int32(o[i-1].X), int32(o[i-1].Y), int32(o[i].X), int32(o[i].Y))
+		}
+		for _, pm := range o {
+			fillCircle(r, int32(pm.X), int32(pm.Y), radius*2/3)
+		}
+		for i, pm := range o {
+			ch.hintLabels.render(r, fmt.Sprintf("%s%d", name, i+1), hintTextColor, int32(pm.X), int32(pm.Y))
+		}
+	}
+}
+
+func setDrawColor(r sdl.Renderer, c sdl.Color) {
+	r.SetDrawColor(c.R, c.G, c.B, c.A)
+}
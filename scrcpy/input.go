@@ -0,0 +1,184 @@
+package scrcpy
+
+import (
+	"log"
+
+	"github.com/ClarkGuan/go-sdl2/sdl"
+)
+
+// 不产生文字的按键，在文字输入模式中总是以按键事件发送
+var sdlKeyCodeMap = map[sdl.Keycode]int{
+	sdl.K_RETURN:    AKEYCODE_ENTER,
+	sdl.K_KP_ENTER:  AKEYCODE_NUMPAD_ENTER,
+	sdl.K_ESCAPE:    AKEYCODE_ESCAPE,
+	sdl.K_BACKSPACE: AKEYCODE_DEL,
+	sdl.K_DELETE:    AKEYCODE_FORWARD_DEL,
+	sdl.K_TAB:       AKEYCODE_TAB,
+	sdl.K_INSERT:    AKEYCODE_INSERT,
+	sdl.K_HOME:      AKEYCODE_MOVE_HOME,
+	sdl.K_END:       AKEYCODE_MOVE_END,
+	sdl.K_PAGEUP:    AKEYCODE_PAGE_UP,
+	sdl.K_PAGEDOWN:  AKEYCODE_PAGE_DOWN,
+	sdl.K_UP:        AKEYCODE_DPAD_UP,
+	sdl.K_DOWN:      AKEYCODE_DPAD_DOWN,
+	sdl.K_LEFT:      AKEYCODE_DPAD_LEFT,
+	sdl.K_RIGHT:     AKEYCODE_DPAD_RIGHT,
+}
+
+// SDL 按键转换为 Android 按键。字母、数字只有在按住 Ctrl、Alt 或 Win 时才作为按键发送
+// （如 Ctrl+A 全选），否则由 SDL_TEXTINPUT 以文字的形式输入，以免重复输入并且支持输入法
+func androidKeyCode(sym sdl.Keycode, mod uint16) (int, bool) {
+	if keyCode, ok := sdlKeyCodeMap[sym]; ok {
+		return keyCode, true
+	}
+	if sym >= sdl.K_F1 && sym <= sdl.K_F12 {
+		return AKEYCODE_F1 + int(sym-sdl.K_F1), true
+	}
+	if mod&(sdl.KMOD_CTRL|sdl.KMOD_ALT|sdl.KMOD_GUI) == 0 {
+		return 0, false
+	}
+	switch {
+	case sym >= sdl.K_a && sym <= sdl.K_z:
+		return AKEYCODE_A + int(sym-sdl.K_a), true
+	case sym >= sdl.K_0 && sym <= sdl.K_9:
+		return AKEYCODE_0 + int(sym-sdl.K_0), true
+	case sym == sdl.K_SPACE:
+		return AKEYCODE_SPACE, true
+	}
+	return 0, false
+}
+
+func androidMetaState(mod uint16) int {
+	var meta int
+	if mod&sdl.KMOD_LSHIFT != 0 {
+		meta |= AMETA_SHIFT_LEFT_ON
+	}
+	if mod&sdl.KMOD_RSHIFT != 0 {
+		meta |= AMETA_SHIFT_RIGHT_ON
+	}
+	if mod&sdl.KMOD_LCTRL != 0 {
+		meta |= AMETA_CTRL_LEFT_ON
+	}
+	if mod&sdl.KMOD_RCTRL != 0 {
+		meta |= AMETA_CTRL_RIGHT_ON
+	}
+	if mod&sdl.KMOD_LALT != 0 {
+		meta |= AMETA_ALT_LEFT_ON
+	}
+	if mod&sdl.KMOD_RALT != 0 {
+		meta |= AMETA_ALT_RIGHT_ON
+	}
+	if mod&sdl.KMOD_LGUI != 0 {
+		meta |= AMETA_META_LEFT_ON
+	}
+	if mod&sdl.KMOD_RGUI != 0 {
+		meta |= AMETA_META_RIGHT_ON
+	}
+	if mod&sdl.KMOD_SHIFT != 0 {
+		meta |= AMETA_SHIFT_ON
+	}
+	if mod&sdl.KMOD_CTRL != 0 {
+		meta |= AMETA_CTRL_ON
+	}
+	if mod&sdl.KMOD_ALT != 0 {
+		meta |= AMETA_ALT_ON
+	}
+	if mod&sdl.KMOD_GUI != 0 {
+		meta |= AMETA_META_ON
+	}
+	if mod&sdl.KMOD_CAPS != 0 {
+		meta |= AMETA_CAPS_LOCK_ON
+	}
+	if mod&sdl.KMOD_NUM != 0 {
+		meta |= AMETA_NUM_LOCK_ON
+	}
+	return meta
+}
+
+// ctrl+t 切换的文字输入模式：键盘不再映射为触摸，而是作为 Android 按键及文字发送到设备，
+// 用于在聊天框等输入框中打字。鼠标点击仍然按照自由鼠标的方式处理
+type textInputHandler struct {
+	controller Controller
+	// 进入时松开游戏中按下的手指
+	onEnter func()
+	toast   func(string)
+
+	active   bool
+	relative bool
+	// 已经发送了 DOWN 的按键，退出时补发 UP
+	pressed map[sdl.Keycode]int
+}
+
+func (ti *textInputHandler) HandleSdlEvent(event sdl.Event) (bool, error) {
+	switch event.GetType() {
+	case sdl.KEYDOWN, sdl.KEYUP:
+		ke := event.(*sdl.KeyboardEvent)
+		if ke.Keysym.Mod&sdl.KMOD_CTRL != 0 && ke.Keysym.Sym == sdl.K_t {
+			if ke.Type == sdl.KEYDOWN && ke.Repeat == 0 {
+				ti.toggle()
+			}
+			return true, nil
+		}
+		if !ti.active {
+			return false, nil
+		}
+		return true, ti.handleKey(ke)
+
+	case sdl.TEXTINPUT:
+		if !ti.active {
+			return false, nil
+		}
+		return true, pushText(ti.controller, event.(*sdl.TextInputEvent).GetText())
+	}
+	return false, nil
+}
+
+func (ti *textInputHandler) handleKey(event *sdl.KeyboardEvent) error {
+	sym := event.Keysym.Sym
+	if event.Type == sdl.KEYUP {
+		keyCode, ok := ti.pressed[sym]
+		if !ok {
+			return nil
+		}
+		delete(ti.pressed, sym)
+		return ti.controller.PushEvent(&keyCodeEvent{action: AKEY_EVENT_ACTION_UP, keyCode: keyCode,
+			metaState: androidMetaState(event.Keysym.Mod)})
+	}
+
+	keyCode, ok := androidKeyCode(sym, event.Keysym.Mod)
+	if !ok {
+		return nil
+	}
+	ti.pressed[sym] = keyCode
+	return ti.controller.PushEvent(&keyCodeEvent{action: AKEY_EVENT_ACTION_DOWN, keyCode: keyCode,
+		metaState: androidMetaState(event.Keysym.Mod)})
+}
+
+func (ti *textInputHandler) toggle() {
+	if ti.active {
+		ti.releaseAll()
+		ti.active = false
+		sdl.StopTextInput()
+		sdl.SetRelativeMouseMode(ti.relative)
+		ti.toast("已返回游戏按键模式")
+		return
+	}
+
+	ti.onEnter()
+	ti.active = true
+	ti.pressed = make(map[sdl.Keycode]int)
+	// 需要用鼠标点击输入框
+	ti.relative = sdl.GetRelativeMouseMode()
+	sdl.SetRelativeMouseMode(false)
+	sdl.StartTextInput()
+	ti.toast("文字输入模式：键盘输入发送到设备，Ctrl+T 返回游戏按键")
+}
+
+func (ti *textInputHandler) releaseAll() {
+	for _, keyCode := range ti.pressed {
+		if err := ti.controller.PushEvent(&keyCodeEvent{action: AKEY_EVENT_ACTION_UP, keyCode: keyCode}); err != nil {
+			log.Println(err)
+		}
+	}
+	ti.pressed = nil
+}
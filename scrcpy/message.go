@@ -0,0 +1,126 @@
+package scrcpy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+)
+
+// 设备发往客户端的消息，格式：类型（1 字节）+ 内容长度（4 字节，大端）+ 内容
+type deviceMessageType byte
+
+const (
+	deviceMsgClipboard deviceMessageType = iota
+	deviceMsgRotation
+	deviceMsgError
+)
+
+// 与服务端 DeviceMessageWriter.PAYLOAD_MAX_LENGTH 一致
+const deviceMessageMaxLength = 1 << 18
+
+type deviceMessage interface {
+	Type() deviceMessageType
+}
+
+// 设备剪贴板内容变化
+type clipboardMessage struct {
+	text string
+}
+
+func (clipboardMessage) Type() deviceMessageType { return deviceMsgClipboard }
+
+// 设备旋转，取值同 Surface.ROTATION_*
+type rotationMessage struct {
+	rotation int
+}
+
+func (rotationMessage) Type() deviceMessageType { return deviceMsgRotation }
+
+// 设备端出错，比如不支持的命令
+type errorMessage struct {
+	text string
+}
+
+func (errorMessage) Type() deviceMessageType { return deviceMsgError }
+
+// 读取一条消息。不认识的类型按长度跳过，返回 nil
+func readDeviceMessage(r io.Reader) (deviceMessage, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > deviceMessageMaxLength {
+		return nil, fmt.Errorf("device message too long: %d bytes", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return parseDeviceMessage(deviceMessageType(header[0]), payload)
+}
+
+func parseDeviceMessage(typ deviceMessageType, payload []byte) (deviceMessage, error) {
+	switch typ {
+	case deviceMsgClipboard:
+		return clipboardMessage{text: string(payload)}, nil
+	case deviceMsgRotation:
+		// 内容有误时跳过这一条，之后的消息仍然可以读取
+		if len(payload) != 1 {
+			log.Printf("bad rotation message (%d bytes), skipped\n", len(payload))
+			return nil, nil
+		}
+		return rotationMessage{rotation: int(payload[0])}, nil
+	case deviceMsgError:
+		return errorMessage{text: string(payload)}, nil
+	}
+	if debugOpt.Debug() {
+		log.Printf("unknown device message type %d, %d bytes skipped\n", typ, len(payload))
+	}
+	return nil, nil
+}
+
+type deviceMessageHandler func(msg deviceMessage)
+
+// 在单独的 goroutine 中读取设备消息，按类型交给注册的处理函数。
+// 处理函数在读取 goroutine 中调用，需要操作窗口的要自己转到 SDL 线程
+type messageReceiver struct {
+	handlers map[deviceMessageType][]deviceMessageHandler
+	mutex    sync.Mutex
+}
+
+func (mr *messageReceiver) register(typ deviceMessageType, h deviceMessageHandler) {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+	if mr.handlers == nil {
+		mr.handlers = make(map[deviceMessageType][]deviceMessageHandler)
+	}
+	mr.handlers[typ] = append(mr.handlers[typ], h)
+}
+
+func (mr *messageReceiver) dispatch(msg deviceMessage) {
+	mr.mutex.Lock()
+	handlers := mr.handlers[msg.Type()]
+	mr.mutex.Unlock()
+	for _, h := range handlers {
+		h(msg)
+	}
+}
+
+// 一直读到连接关闭，断线重连后对新的连接再次调用
+func (mr *messageReceiver) run(r io.Reader) {
+	for {
+		msg, err := readDeviceMessage(r)
+		if err != nil {
+			if debugOpt.Debug() {
+				log.Println("device messages stopped:", err)
+			}
+			return
+		}
+		if msg != nil {
+			mr.dispatch(msg)
+		}
+	}
+}
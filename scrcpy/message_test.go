@@ -0,0 +1,50 @@
+package scrcpy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func encodeDeviceMessage(typ byte, payload []byte) []byte {
+	buf := []byte{typ, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(payload)))
+	return append(buf, payload...)
+}
+
+func TestReceiverDispatch(t *testing.T) {
+	var stream bytes.Buffer
+	stream.Write(encodeDeviceMessage(byte(deviceMsgClipboard), []byte("复制的文字")))
+	// 不认识的类型按长度跳过
+	stream.Write(encodeDeviceMessage(42, []byte{1, 2, 3}))
+	stream.Write(encodeDeviceMessage(byte(deviceMsgRotation), []byte{3}))
+	// 内容有误的消息被跳过，不影响之后的消息
+	stream.Write(encodeDeviceMessage(byte(deviceMsgRotation), []byte{1, 2}))
+	stream.Write(encodeDeviceMessage(byte(deviceMsgError), []byte("oops")))
+	stream.Write(encodeDeviceMessage(byte(deviceMsgClipboard), []byte("after")))
+
+	var got []deviceMessage
+	var mr messageReceiver
+	record := func(msg deviceMessage) { got = append(got, msg) }
+	mr.register(deviceMsgClipboard, record)
+	mr.register(deviceMsgRotation, record)
+	mr.run(&stream)
+
+	want := []deviceMessage{clipboardMessage{text: "复制的文字"}, rotationMessage{rotation: 3}, clipboardMessage{text: "after"}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestReadDeviceMessageTooLong(t *testing.T) {
+	buf := []byte{byte(deviceMsgClipboard), 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(buf[1:], deviceMessageMaxLength+1)
+	if _, err := readDeviceMessage(bytes.NewReader(buf)); err == nil {
+		t.Fatal("want error for an oversized message")
+	}
+}
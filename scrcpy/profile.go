@@ -0,0 +1,132 @@
+package scrcpy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 配置目录中包名与配置文件对应关系的文件名
+const ProfilesFile = "profiles.yml"
+
+// 查询前台应用的间隔
+const profilePollInterval = 2 * time.Second
+
+// 按前台应用切换配置文件，profiles.yml 的格式：
+//
+//	default: settings.yml
+//	packages:
+//	  com.tencent.tmgp.pubgmhd: pubg.yml
+//	  com.tencent.tmgp.sgame: sgame.yml
+//
+// 文件路径相对于配置目录。没有对应的包名时使用 default，default 为空时使用 -cfg 指定的文件
+type Profiles struct {
+	Dir      string            `yaml:"-"`
+	Default  string            `yaml:"default"`
+	Packages map[string]string `yaml:"packages"`
+}
+
+// 读取 dir 下的 profiles.yml，并检查其中的每一个配置文件
+func LoadProfiles(dir string) (*Profiles, error) {
+	path := filepath.Join(dir, ProfilesFile)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	profiles := Profiles{Dir: dir}
+	if err = yaml.Unmarshal(content, &profiles); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	files := []string{profiles.Default}
+	for _, file := range profiles.Packages {
+		files = append(files, file)
+	}
+	for _, file := range files {
+		if len(file) == 0 {
+			continue
+		}
+		if _, err = LoadConfig(profiles.resolve(file)); err != nil {
+			return nil, err
+		}
+	}
+	return &profiles, nil
+}
+
+func (p *Profiles) resolve(file string) string {
+	if len(file) == 0 || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(p.Dir, file)
+}
+
+// 返回包名对应的配置文件路径，没有对应的配置时返回 default（可能为空）
+func (p *Profiles) Path(pkg string) string {
+	if file, ok := p.Packages[pkg]; ok {
+		return p.resolve(file)
+	}
+	return p.resolve(p.Default)
+}
+
+// 查询设备当前的前台应用，无法确定时（如下拉了通知栏）返回空字符串
+func foregroundPackage(serial string) (string, error) {
+	out, err := adbOutput(serial, "shell", "dumpsys", "window")
+	if err != nil {
+		return "", err
+	}
+	if pkg := parseForegroundPackage(string(out), "mCurrentFocus=", "mFocusedApp="); len(pkg) > 0 {
+		return pkg, nil
+	}
+
+	// 部分系统的 dumpsys window 中没有焦点信息
+	if out, err = adbOutput(serial, "shell", "dumpsys", "activity", "activities"); err != nil {
+		return "", err
+	}
+	return parseForegroundPackage(string(out), "topResumedActivity=", "mResumedActivity:", "ResumedActivity:"), nil
+}
+
+// 依次查找包含 keys 的行，取其中第一个形如 包名/Activity 的字段，例如：
+//
+//	mCurrentFocus=Window{4a1f3c0 u0 com.android.launcher3/com.android.launcher3.Launcher}
+//	mResumedActivity: ActivityRecord{9d2c1e8 u0 com.tencent.tmgp.pubgmhd/.MainActivity t231}
+func parseForegroundPackage(out string, keys ...string) string {
+	lines := strings.Split(out, "\n")
+	for _, key := range keys {
+		for _, line := range lines {
+			i := strings.Index(line, key)
+			if i < 0 {
+				continue
+			}
+			for _, field := range strings.Fields(line[i+len(key):]) {
+				if j := strings.Index(field, "/"); j > 0 {
+					return strings.TrimLeft(field[:j], "{")
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// 定时查询前台应用，包名发生变化时调用 changed，直到 stop 被关闭
+func watchForeground(serial string, interval time.Duration, stop <-chan struct{}, changed func(pkg string)) {
+	var last string
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pkg, err := foregroundPackage(serial)
+		if err == nil && len(pkg) > 0 && pkg != last {
+			last = pkg
+			changed(pkg)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
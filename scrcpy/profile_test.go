@@ -0,0 +1,46 @@
+package scrcpy
+
+import "testing"
+
+func TestParseForegroundPackage(t *testing.T) {
+	windowKeys := []string{"mCurrentFocus=", "mFocusedApp="}
+	activityKeys := []string{"topResumedActivity=", "mResumedActivity:", "ResumedActivity:"}
+	tests := []struct {
+		name string
+		out  string
+		keys []string
+		want string
+	}{
+		{"current focus", `WINDOW MANAGER WINDOWS (dumpsys window windows)
+  Window #0 Window{6c2d0b7 u0 NavigationBar0}:
+    mDisplayId=0 rootTaskId=1 mSession=Session{2b6f1e4 1520:u0a10122} mClient=android.os.BinderProxy@5f0a8c
+  mCurrentFocus=Window{4a1f3c0 u0 com.tencent.tmgp.pubgmhd/com.epicgames.ue4.GameActivity}
+  mFocusedApp=ActivityRecord{9d2c1e8 u0 com.tencent.tmgp.pubgmhd/com.epicgames.ue4.GameActivity t231}
+`, windowKeys, "com.tencent.tmgp.pubgmhd"},
+		// 下拉通知栏时焦点窗口没有包名，使用 mFocusedApp
+		{"notification shade", `  mCurrentFocus=Window{8f1c2a u0 NotificationShade}
+  mFocusedApp=ActivityRecord{77ad1b2 u0 com.android.launcher3/.uioverrides.QuickstepLauncher t5}
+`, windowKeys, "com.android.launcher3"},
+		{"old focused app", `  mFocusedApp=AppWindowToken{3f1a2b token=Token{5e2c3d ActivityRecord{9d2e4f u0 com.sina.weibo/.SplashActivity t3}}}
+`, windowKeys, "com.sina.weibo"},
+		{"no focus", `  mCurrentFocus=null
+  mFocusedApp=null
+`, windowKeys, ""},
+		{"top resumed activity", `ACTIVITY MANAGER ACTIVITIES (dumpsys activity activities)
+Display #0 (activities from top to bottom):
+  * Task{3c2b1a #42 type=standard A=10245:com.miHoYo.Yuanshen U=0 visible=true mode=fullscreen}
+    topResumedActivity=ActivityRecord{b1e0c2 u0 com.miHoYo.Yuanshen/com.miHoYo.GetMobileInfo.MainActivity t42}
+`, activityKeys, "com.miHoYo.Yuanshen"},
+		{"resumed activity", `  Stack #1: type=standard mode=fullscreen
+    mResumedActivity: ActivityRecord{9d2c1e8 u0 com.tencent.tmgp.sgame/.SGameActivity t231}
+`, activityKeys, "com.tencent.tmgp.sgame"},
+		{"empty", "", windowKeys, ""},
+		{"service missing", "Can't find service: window\n", windowKeys, ""},
+	}
+
+	for _, tt := range tests {
+		if got := parseForegroundPackage(tt.out, tt.keys...); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+//go:build !nolibav
+// +build !nolibav
+
+#include "record.h"
+
+#include <libavutil/time.h>
+#include <stdio.h>
+#include <string.h>
+
+static const AVRational micro_time_base = {1, 1000000};
+
+struct recorder *recorder_open(const char *filename, const char *format_name, int width, int height) {
+    struct recorder *r = av_mallocz(sizeof(struct recorder));
+    if (!r) {
+        fprintf(stderr, "Could not allocate recorder\n");
+        return NULL;
+    }
+
+    if (avformat_alloc_output_context2(&r->ctx, NULL, format_name, filename) < 0) {
+        fprintf(stderr, "Could not allocate output context for %s\n", format_name);
+        goto open_fail;
+    }
+
+    AVStream *stream = avformat_new_stream(r->ctx, NULL);
+    if (!stream) {
+        fprintf(stderr, "Could not create output stream\n");
+        goto open_free_ctx;
+    }
+    stream->codecpar->codec_type = AVMEDIA_TYPE_VIDEO;
+    stream->codecpar->codec_id = AV_CODEC_ID_H264;
+    stream->codecpar->format = AV_PIX_FMT_YUV420P;
+    stream->codecpar->width = width;
+    stream->codecpar->height = height;
+    stream->time_base = micro_time_base;
+
+    if (avio_open(&r->ctx->pb, filename, AVIO_FLAG_WRITE) < 0) {
+        fprintf(stderr, "Could not open output file %s\n", filename);
+        goto open_free_ctx;
+    }
+
+    r->last_pts = AV_NOPTS_VALUE;
+    return r;
+
+open_free_ctx:
+    avformat_free_context(r->ctx);
+open_fail:
+    av_free(r);
+    return NULL;
+}
+
+// 码流中第一个包包含 SPS/PPS，用作 extradata 后才写入文件头
+static int recorder_write_header(struct recorder *r, const AVPacket *packet) {
+    AVStream *stream = r->ctx->streams[0];
+    uint8_t *extradata = av_malloc(packet->size + AV_INPUT_BUFFER_PADDING_SIZE);
+    if (!extradata) {
+        return AVERROR(ENOMEM);
+    }
+    memcpy(extradata, packet->data, packet->size);
+    memset(extradata + packet->size, 0, AV_INPUT_BUFFER_PADDING_SIZE);
+    stream->codecpar->extradata = extradata;
+    stream->codecpar->extradata_size = packet->size;
+
+    int ret = avformat_write_header(r->ctx, NULL);
+    if (ret < 0) {
+        fprintf(stderr, "Could not write header: %d\n", ret);
+        return ret;
+    }
+    r->header_written = 1;
+    return 0;
+}
+
+// 设备端不发送 PTS，使用收到数据包时的本地时钟作为时间戳
+int recorder_write(struct recorder *r, const AVPacket *packet) {
+    int ret;
+    if (!r->header_written) {
+        if ((ret = recorder_write_header(r, packet)) < 0) {
+            return ret;
+        }
+        r->start_time = av_gettime_relative();
+    }
+
+    AVPacket rec;
+    av_init_packet(&rec);
+    if ((ret = av_packet_ref(&rec, packet)) < 0) {
+        return ret;
+    }
+
+    int64_t pts = av_gettime_relative() - r->start_time;
+    if (r->last_pts != AV_NOPTS_VALUE && pts <= r->last_pts) {
+        pts = r->last_pts + 1;
+    }
+    r->last_pts = pts;
+
+    rec.stream_index = 0;
+    rec.pts = pts;
+    rec.dts = pts;
+    rec.duration = 0;
+    av_packet_rescale_ts(&rec, micro_time_base, r->ctx->streams[0]->time_base);
+    ret = av_write_frame(r->ctx, &rec);
+    av_packet_unref(&rec);
+    return ret;
+}
+
+void recorder_close(struct recorder *r) {
+    if (r->header_written) {
+        int ret = av_write_trailer(r->ctx);
+        if (ret < 0) {
+            fprintf(stderr, "Could not write trailer: %d\n", ret);
+        }
+    }
+    avio_closep(&r->ctx->pb);
+    avformat_free_context(r->ctx);
+    av_free(r);
+}
@@ -0,0 +1,77 @@
+//go:build !nolibav
+// +build !nolibav
+
+package scrcpy
+
+// #include <stdlib.h>
+// #include "record.h"
+import "C"
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+var errRecorderClosed = errors.New("recorder already closed")
+
+// 根据文件扩展名选择封装格式
+func recordFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4":
+		return "mp4", nil
+	case ".mkv":
+		return "matroska", nil
+	}
+	return "", fmt.Errorf("unsupported record format: %s (use .mp4 or .mkv)", path)
+}
+
+// 将设备发送的 H.264 码流原样封装到 mp4/mkv 文件中
+type recorder struct {
+	r     *C.struct_recorder
+	mutex sync.Mutex
+}
+
+func newRecorder(path string, frameSize size) (*recorder, error) {
+	format, err := recordFormat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cPath, cFormat := C.CString(path), C.CString(format)
+	defer C.free(unsafe.Pointer(cPath))
+	defer C.free(unsafe.Pointer(cFormat))
+
+	r := C.recorder_open(cPath, cFormat, C.int(frameSize.width), C.int(frameSize.height))
+	if r == nil {
+		return nil, fmt.Errorf("could not open record file: %s", path)
+	}
+	return &recorder{r: r}, nil
+}
+
+func (rec *recorder) write(packet *C.AVPacket) error {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	if rec.r == nil {
+		return errRecorderClosed
+	}
+	if ret := C.recorder_write(rec.r, packet); ret < 0 {
+		return fmt.Errorf("could not write packet to record file: %d", int(ret))
+	}
+	return nil
+}
+
+// 写入文件尾，可重复调用
+func (rec *recorder) Close() error {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	if rec.r != nil {
+		C.recorder_close(rec.r)
+		rec.r = nil
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+#ifndef SCRCPY_RECORD_H
+#define SCRCPY_RECORD_H
+
+#include <libavformat/avformat.h>
+
+struct recorder {
+    AVFormatContext *ctx;
+    int header_written;
+    int64_t start_time;
+    int64_t last_pts;
+};
+
+struct recorder *recorder_open(const char *filename, const char *format_name, int width, int height);
+int recorder_write(struct recorder *r, const AVPacket *packet);
+void recorder_close(struct recorder *r);
+
+#endif
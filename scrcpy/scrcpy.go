@@ -1,96 +1,161 @@
 package scrcpy
 
 import (
+	"errors"
 	"log"
+	"os"
+	"os/signal"
 	"runtime"
+	"syscall"
 	"time"
 
 	"github.com/ClarkGuan/go-sdl2/sdl"
 )
 
 type Option struct {
-	Serial         string
-	Port           int
-	OverTcp        bool
-	BitRate        int
+	Serial     string
+	Port       int
+	OverTcp    bool
+	BitRate    int
+	Decoder    string
+	RecordPath string
+	NoDisplay  bool
+	ApiAddr    string
+	Script     string
+	// 断线重连次数，0 表示不重连，小于 0 表示不限次数
+	ReconnectRetries int
+	// 首次重连的等待时间，之后逐次翻倍
+	ReconnectDelay time.Duration
 	Debug          DebugLevel
-	KeyMap         map[int]UserOperation
-	CtrlKeyMap     map[int]UserOperation
-	MouseKeyMap    map[uint8]UserOperation
 	MouseSensitive float64
-	Hits           []time.Duration
-	Stables        []*GunPressConfig
+	// 按键映射，ConfigPath 不为空时监视该文件并在修改后重新加载
+	Config
+	ConfigPath string
+	// 不为空时按设备前台应用切换配置文件
+	Profiles *Profiles
+
+	// 窗口模式
+	Fullscreen  bool
+	AlwaysOnTop bool
+	Borderless  bool
+
+	// 同时按下的手指数量上限（不大于 0 时为 DefaultMaxFingers），以及手指用完时的处理方式
+	MaxFingers   int
+	FingerPolicy FingerPolicy
 }
 
-func Main(opt *Option) (err error) {
+func Main(opt *Option) error {
+	return MainSessions(opt)
+}
+
+// 同时连接多个设备（由 Option.Serial 区分），每个设备一个窗口，彼此独立
+func MainSessions(opts ...*Option) (err error) {
+	if len(opts) == 0 {
+		return errors.New("no device specified")
+	}
+
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 
-	debugOpt = opt.Debug
-	mouseSensitive = opt.MouseSensitive
-	setConfigs(opt.Hits, opt.Stables)
+	// 日志等级是进程级别的设置
+	debugOpt = opts[0].Debug
 
-	svr := server{}
-	svrOpt := serverOption{serial: opt.Serial, localPort: opt.Port, bitRate: opt.BitRate, overTcp: opt.OverTcp}
-
-	if err = svr.Start(&svrOpt); err != nil {
-		return
+	display := false
+	for _, opt := range opts {
+		if !opt.NoDisplay {
+			display = true
+		}
+	}
+	if display {
+		if err = sdlInitAndConfigure(); err != nil {
+			return
+		}
+		defer sdl.Quit()
 	}
+
+	var sessions []*Session
 	defer func() {
-		svr.Stop()
-		svr.Close()
+		for _, s := range sessions {
+			s.Close()
+		}
 	}()
 
-	if err = sdlInitAndConfigure(); err != nil {
-		return
+	usedPorts := make(map[int]bool)
+	for _, opt := range opts {
+		s := NewSession(opt)
+		// 端口冲突时自动分配
+		if !opt.OverTcp {
+			if usedPorts[s.localPort] {
+				s.localPort = 0
+			}
+			usedPorts[s.localPort] = true
+		}
+		sessions = append(sessions, s)
+		if err = s.Start(); err != nil {
+			return
+		}
 	}
 
-	if err = svr.ConnectTo(); err != nil {
-		return
+	if !display {
+		return waitSessions(sessions)
 	}
 
-	var deviceName string
-	var screenSize size
-	if deviceName, screenSize, err = svr.ReadDeviceInfo(); err != nil {
-		return
-	}
-	if debugOpt.Debug() {
-		log.Printf("device name: %s, screen %v\n", deviceName, screenSize)
-	}
+	// SDL 没有接管信号处理，收到退出信号时通过 SDL_QUIT 正常退出，保证录像文件完整
+	handleQuitSignals()
 
-	frames := frame{}
-	if err = frames.Init(); err != nil {
-		return
+	looper := NewSdlEventLooper()
+	gamepads := newGamepadRouter(sessions)
+	defer gamepads.Close()
+	looper.Register(gamepads)
+	for _, s := range sessions {
+		if !s.opt.NoDisplay {
+			looper.Register(s)
+		}
 	}
-	defer frames.Close()
 
-	decoder := getDecoder(&frames, svr.deviceConn)
-	decoder.Start()
-
-	screen := screen{}
-	if err = screen.InitRendering(deviceName, screenSize); err != nil {
-		return
+	if err = looper.Loop(); err != nil {
+		log.Println(err)
 	}
+	return
+}
 
-	controller := newController(svr.deviceConn, &screen)
-	controller.Start()
+// 不显示画面时等待所有设备结束或者收到退出信号
+func waitSessions(sessions []*Session) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	for _, s := range sessions {
+		select {
+		case <-s.done:
+			if s.err != nil {
+				return s.err
+			}
+		case <-signals:
+			return nil
+		}
+	}
+	return nil
+}
 
-	looper := NewSdlEventLooper()
+func handleQuitSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		sdl.PushEvent(&sdl.QuitEvent{Type: sdl.QUIT})
+	}()
+}
 
-	fh := &frameHandler{screen: &screen, frames: &frames}
-	looper.Register(fh)
+// 不显示画面时只关心视频流何时结束
+type decoderStopListener func()
 
-	ch := newControlHandler(controller,
-		opt.KeyMap,
-		opt.CtrlKeyMap,
-		opt.MouseKeyMap)
-	looper.Register(ch)
-	screen.addRendererFunc(ch)
+func (l decoderStopListener) OnNewFrame() {
+}
 
-	if err = looper.Loop(); err != nil {
-		log.Println(err)
-	}
+func (l decoderStopListener) OnFrameSizeChanged(old, new size) {
+}
 
-	sdl.Quit()
-	return
+func (l decoderStopListener) OnDecoderStopped() {
+	l()
 }
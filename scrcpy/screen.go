@@ -2,6 +2,7 @@ package scrcpy
 
 import (
 	"log"
+	"math"
 
 	"github.com/ClarkGuan/go-sdl2/sdl"
 )
@@ -19,6 +20,10 @@ func sdlInitAndConfigure() (err error) {
 		return
 	}
 
+	if err := sdl.InitSubSystem(sdl.INIT_GAMECONTROLLER); err != nil {
+		log.Printf("Could not initialize gamepad support: %v\n", err)
+	}
+
 	if !sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "2") {
 		log.Println("Could not enable bilinear filtering")
 	}
@@ -86,32 +91,34 @@ func getInitialOptimalSize(frameSize size) size {
 }
 
 type screen struct {
-	window    sdl.Window
-	renderer  sdl.Renderer
-	texture   sdl.Texture
-	frameSize size
-	hasFrame  bool
-	Renderers []Renderer
-	initFlag  bool
-	bufs      []byte
+	window        sdl.Window
+	renderer      sdl.Renderer
+	texture       sdl.Texture
+	textureFormat uint32
+	frameSize     size
+	hasFrame      bool
+	Renderers     []Renderer
+	initFlag      bool
+	bufs          []byte
+
+	// 创建窗口时额外的标志，如置顶、无边框、全屏
+	windowFlags uint32
+	fullscreen  bool
 }
 
 func (s *screen) InitRendering(deviceName string, frameSize size) (err error) {
 	s.frameSize = frameSize
 	windowSize := getInitialOptimalSize(frameSize)
-	windowFlags := sdl.WINDOW_HIDDEN // SDL_WINDOW_RESIZABLE
+	windowFlags := sdl.WINDOW_HIDDEN | sdl.WINDOW_RESIZABLE
 	windowFlags |= sdl.WINDOW_ALLOW_HIGHDPI
-	if s.window, err = sdl.CreateWindow(deviceName, sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, int32(windowSize.width), int32(windowSize.height), uint32(windowFlags)); err != nil {
+	s.fullscreen = s.windowFlags&sdl.WINDOW_FULLSCREEN != 0
+	if s.window, err = sdl.CreateWindow(deviceName, sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED, int32(windowSize.width), int32(windowSize.height), uint32(windowFlags)|s.windowFlags); err != nil {
 		return
 	}
 	if s.renderer, err = sdl.CreateRenderer(s.window, -1, sdl.RENDERER_ACCELERATED); err != nil {
 		s.Close()
 		return
 	}
-	if err = s.renderer.SetLogicalSize(int32(frameSize.width), int32(frameSize.height)); err != nil {
-		s.Close()
-		return
-	}
 	if debugOpt.Debug() {
 		log.Printf("Initial texture: %d, %d", frameSize.width, frameSize.height)
 	}
@@ -144,18 +151,16 @@ func (s *screen) showWindow() {
 
 func (s *screen) prepareForFrame(newFrameSize size) (err error) {
 	if s.frameSize.width != newFrameSize.width || s.frameSize.height != newFrameSize.height {
-		if err = s.renderer.SetLogicalSize(int32(newFrameSize.width), int32(newFrameSize.height)); err != nil {
-			log.Printf("Could not set renderer logical size: %v\n", err)
-			return
-		}
-
 		s.texture.Destroy()
-		w, h := s.window.GetSize()
-		currentSize := size{width: uint16(w), height: uint16(h)}
-		targetSize := size{width: uint16(uint32(currentSize.width) * uint32(newFrameSize.width) / uint32(s.frameSize.width)),
-			height: uint16(uint32(currentSize.height) * uint32(newFrameSize.height) / uint32(s.frameSize.height))}
-		targetSize = getOptimalSize(targetSize, newFrameSize)
-		s.window.SetSize(int32(targetSize.width), int32(targetSize.height))
+		// 全屏或者最大化时保持窗口大小，画面按比例缩放并在两侧留黑边
+		if !s.fullscreen && s.window.GetFlags()&sdl.WINDOW_MAXIMIZED == 0 {
+			w, h := s.window.GetSize()
+			currentSize := size{width: uint16(w), height: uint16(h)}
+			targetSize := size{width: uint16(uint32(currentSize.width) * uint32(newFrameSize.width) / uint32(s.frameSize.width)),
+				height: uint16(uint32(currentSize.height) * uint32(newFrameSize.height) / uint32(s.frameSize.height))}
+			targetSize = getOptimalSize(targetSize, newFrameSize)
+			s.window.SetSize(int32(targetSize.width), int32(targetSize.height))
+		}
 		s.frameSize = newFrameSize
 		if debugOpt.Debug() {
 			log.Printf("New texture: %d, %d\n", newFrameSize.width, newFrameSize.height)
@@ -176,7 +181,16 @@ func (s *screen) render() {
 			r.Init(s.renderer)
 		}
 	}
+	// 画面按比例绘制在视口中，之后的叠加层都使用设备画面坐标绘制
+	vp := s.viewport()
+	rect := vp.rect()
+	s.renderer.SetViewport(nil)
+	s.renderer.SetScale(1, 1)
 	s.renderer.Clear()
+	if rect.W > 0 && rect.H > 0 {
+		s.renderer.SetViewport(&rect)
+		s.renderer.SetScale(float32(rect.W)/float32(s.frameSize.width), float32(rect.H)/float32(s.frameSize.height))
+	}
 	s.renderer.Copy(s.texture, nil, nil)
 	for _, r := range s.Renderers {
 		r.Render(s.renderer)
@@ -185,11 +199,170 @@ func (s *screen) render() {
 }
 
 func (s *screen) createTexture(w, h uint16) (err error) {
-	// 在 MacOS 上可以创建 NV12 的 Texture 进行硬件加速
-	s.texture, err = s.renderer.CreateTexture(sdl.PIXELFORMAT_NV12, sdl.TEXTUREACCESS_STREAMING, int32(w), int32(h))
+	// 硬件解码输出 NV12，软件解码输出 YUV420P（对应 SDL 的 IYUV）
+	if s.textureFormat == 0 {
+		s.textureFormat = sdl.PIXELFORMAT_NV12
+	}
+	s.texture, err = s.renderer.CreateTexture(s.textureFormat, sdl.TEXTUREACCESS_STREAMING, int32(w), int32(h))
+	return
+}
+
+func (s *screen) prepareTextureFormat(format uint32) (err error) {
+	if s.textureFormat != format {
+		if debugOpt.Debug() {
+			log.Printf("New texture format: %d\n", format)
+		}
+		s.texture.Destroy()
+		s.textureFormat = format
+		if err = s.createTexture(s.frameSize.width, s.frameSize.height); err != nil {
+			log.Printf("Could not create texture: %v\n", err)
+		}
+	}
 	return
 }
 
+func (s *screen) toggleFullscreen() {
+	var flags uint32
+	if !s.fullscreen {
+		flags = sdl.WINDOW_FULLSCREEN_DESKTOP
+	}
+	if err := s.window.SetFullscreen(flags); err != nil {
+		log.Printf("Could not switch fullscreen mode: %v\n", err)
+		return
+	}
+	s.fullscreen = !s.fullscreen
+}
+
+// 调整窗口大小，使一个设备像素对应一个屏幕像素（考虑 HiDPI 的缩放）
+func (s *screen) resizeToPixelPerfect() {
+	if s.fullscreen {
+		return
+	}
+	ww, wh := s.window.GetSize()
+	ow, oh, err := s.renderer.GetOutputSize()
+	if err != nil || ww == 0 || wh == 0 {
+		return
+	}
+	scaleX := float64(ow) / float64(ww)
+	scaleY := float64(oh) / float64(wh)
+	s.window.SetSize(int32(math.Round(float64(s.frameSize.width)/scaleX)), int32(math.Round(float64(s.frameSize.height)/scaleY)))
+}
+
+// 在不超出显示器可用区域的前提下，按画面比例使窗口尽可能大（没有黑边）
+func (s *screen) resizeToFit() {
+	if s.fullscreen {
+		return
+	}
+	if s.window.GetFlags()&sdl.WINDOW_MAXIMIZED != 0 {
+		s.window.Restore()
+	}
+	fit := getOptimalSize(size{width: math.MaxUint16, height: math.MaxUint16}, s.frameSize)
+	s.window.SetSize(int32(fit.width), int32(fit.height))
+}
+
+func (s *screen) viewport() viewport {
+	vp := viewport{frame: s.frameSize}
+	vp.windowW, vp.windowH = s.window.GetSize()
+	if w, h, err := s.renderer.GetOutputSize(); err == nil {
+		vp.outputW, vp.outputH = w, h
+	} else {
+		vp.outputW, vp.outputH = vp.windowW, vp.windowH
+	}
+	return vp
+}
+
+// 将鼠标事件的窗口坐标转换为设备画面坐标
+func (s *screen) devicePoint(x, y int32) Point {
+	return s.viewport().devicePoint(x, y)
+}
+
+// 窗口坐标与设备画面坐标之间的换算。鼠标事件使用窗口坐标，HiDPI 时渲染输出的像素数
+// 大于窗口大小；画面保持比例居中绘制，窗口比例不同（如设备旋转后）时两侧留黑边
+type viewport struct {
+	windowW, windowH int32
+	outputW, outputH int32
+	frame            size
+}
+
+// 画面在渲染输出中的区域（像素）
+func (v viewport) rect() sdl.Rect {
+	if v.frame.width == 0 || v.frame.height == 0 || v.outputW <= 0 || v.outputH <= 0 {
+		return sdl.Rect{}
+	}
+	fw, fh := int64(v.frame.width), int64(v.frame.height)
+	w, h := int64(v.outputW), int64(v.outputH)
+	if fw*h > fh*w {
+		h = fh * w / fw
+	} else {
+		w = fw * h / fh
+	}
+	return sdl.Rect{X: (v.outputW - int32(w)) / 2, Y: (v.outputH - int32(h)) / 2, W: int32(w), H: int32(h)}
+}
+
+// 点击黑边或者拖动到窗口之外时，取画面上最近的点
+func (v viewport) devicePoint(x, y int32) Point {
+	rect := v.rect()
+	if rect.W == 0 || rect.H == 0 || v.windowW <= 0 || v.windowH <= 0 {
+		return Point{}
+	}
+	px := float64(x) * float64(v.outputW) / float64(v.windowW)
+	py := float64(y) * float64(v.outputH) / float64(v.windowH)
+	fx := (px - float64(rect.X)) * float64(v.frame.width) / float64(rect.W)
+	fy := (py - float64(rect.Y)) * float64(v.frame.height) / float64(rect.H)
+	return Point{X: clampCoordinate(fx, v.frame.width), Y: clampCoordinate(fy, v.frame.height)}
+}
+
+func clampCoordinate(v float64, length uint16) uint16 {
+	if v < 0 || length == 0 {
+		return 0
+	}
+	if v >= float64(length) {
+		return length - 1
+	}
+	return uint16(v)
+}
+
+// 窗口相关的快捷键：ctrl+f 全屏，ctrl+g 1:1 像素，ctrl+w 适应显示器
+type windowHandler struct {
+	screen *screen
+}
+
+func (wh *windowHandler) HandleSdlEvent(event sdl.Event) (bool, error) {
+	switch event.GetType() {
+	case sdl.WINDOWEVENT:
+		switch event.(*sdl.WindowEvent).Event {
+		case sdl.WINDOWEVENT_EXPOSED, sdl.WINDOWEVENT_SIZE_CHANGED:
+			// 画面静止时设备不会发送新的视频帧，需要自己重新绘制
+			if wh.screen.hasFrame {
+				wh.screen.render()
+			}
+			return true, nil
+		}
+
+	case sdl.KEYDOWN, sdl.KEYUP:
+		ke := event.(*sdl.KeyboardEvent)
+		if ke.Keysym.Mod&sdl.KMOD_CTRL == 0 {
+			return false, nil
+		}
+		var action func()
+		switch ke.Keysym.Sym {
+		case sdl.K_f:
+			action = wh.screen.toggleFullscreen
+		case sdl.K_g:
+			action = wh.screen.resizeToPixelPerfect
+		case sdl.K_w:
+			action = wh.screen.resizeToFit
+		default:
+			return false, nil
+		}
+		if ke.Type == sdl.KEYDOWN && ke.Repeat == 0 {
+			action()
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
 func (s *screen) addRendererFunc(r Renderer) {
 	s.Renderers = append(s.Renderers, r)
 }
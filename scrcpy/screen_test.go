@@ -0,0 +1,44 @@
+package scrcpy
+
+import "testing"
+
+func TestViewportDevicePoint(t *testing.T) {
+	portrait := size{width: 1080, height: 2248}
+	landscape := size{width: 2248, height: 1080}
+	tests := []struct {
+		name             string
+		windowW, windowH int32
+		outputW, outputH int32
+		frame            size
+		x, y             int32
+		want             Point
+	}{
+		{"exact", 1080, 2248, 1080, 2248, portrait, 540, 1124, Point{540, 1124}},
+		{"scaled", 540, 1124, 540, 1124, portrait, 270, 562, Point{540, 1124}},
+		{"hidpi", 540, 1124, 1080, 2248, portrait, 270, 562, Point{540, 1124}},
+		{"hidpi corner", 540, 1124, 1080, 2248, portrait, 539, 1123, Point{1078, 2246}},
+		// 窗口比画面宽，左右各有 (1000-540)/2 = 230 的黑边
+		{"pillarbox", 1000, 1124, 1000, 1124, portrait, 230 + 135, 281, Point{270, 562}},
+		{"pillarbox left", 1000, 1124, 1000, 1124, portrait, 100, 281, Point{0, 562}},
+		{"pillarbox right", 1000, 1124, 1000, 1124, portrait, 900, 281, Point{1079, 562}},
+		// 窗口比画面高，上下各有 (1000-480)/2 = 260 的黑边
+		{"letterbox", 1000, 1000, 1000, 1000, landscape, 500, 260 + 240, Point{1124, 540}},
+		{"letterbox top", 1000, 1000, 1000, 1000, landscape, 500, 10, Point{1124, 0}},
+		{"letterbox hidpi", 500, 500, 1000, 1000, landscape, 250, 250, Point{1124, 540}},
+		// 设备旋转后窗口大小不变，画面居中绘制
+		{"rotated", 1080, 2248, 1080, 2248, landscape, 540, 1124, Point{1124, 540}},
+		{"rotated outside", 540, 1124, 540, 1124, landscape, 270, 100, Point{1124, 0}},
+		{"negative", 540, 1124, 540, 1124, portrait, -20, -5, Point{0, 0}},
+		{"beyond", 540, 1124, 540, 1124, portrait, 600, 1200, Point{1079, 2247}},
+		{"no frame", 540, 1124, 540, 1124, size{}, 10, 10, Point{0, 0}},
+		{"no window", 0, 0, 0, 0, portrait, 10, 10, Point{0, 0}},
+	}
+
+	for _, test := range tests {
+		v := viewport{windowW: test.windowW, windowH: test.windowH,
+			outputW: test.outputW, outputH: test.outputH, frame: test.frame}
+		if got := v.devicePoint(test.x, test.y); got != test.want {
+			t.Errorf("%s: devicePoint(%d, %d) = %v, want %v", test.name, test.x, test.y, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package scrcpy
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// 鼠标滚轮在设备上的滚动，与服务端 ControlEventReader.parseScrollControlEvent 对应。
+// hScroll、vScroll 的单位是滚轮的格数，向右、向上为正
+type scrollEvent struct {
+	Point
+	hScroll int32
+	vScroll int32
+}
+
+func (se *scrollEvent) EventType() controlEventType {
+	return CONTROL_EVENT_TYPE_SCROLL
+}
+
+func (se *scrollEvent) Serialize(w io.Writer, data ...interface{}) error {
+	s := data[0].(*screen)
+	buf := make([]byte, 17)
+	buf[0] = byte(se.EventType())
+	binary.BigEndian.PutUint16(buf[1:], se.X)
+	binary.BigEndian.PutUint16(buf[3:], se.Y)
+	binary.BigEndian.PutUint16(buf[5:], s.frameSize.width)
+	binary.BigEndian.PutUint16(buf[7:], s.frameSize.height)
+	binary.BigEndian.PutUint32(buf[9:], uint32(se.hScroll))
+	binary.BigEndian.PutUint32(buf[13:], uint32(se.vScroll))
+	_, err := w.Write(buf)
+	return err
+}
@@ -19,6 +19,9 @@ const (
 	deviceServerPath = "/data/local/tmp/scrcpy-server.jar"
 	sockName         = "scrcpy"
 	defaultMainClass = "com.genymobile.scrcpy.Server"
+
+	// 服务端在握手之后建立消息连接，超时后只使用视频连接
+	messageConnTimeout = 2 * time.Second
 )
 
 type serverOption struct {
@@ -43,8 +46,9 @@ type server struct {
 	listener           net.Listener
 	tunnelEnable       bool
 
-	serverProc *exec.Cmd
-	deviceConn net.Conn
+	serverProc  *exec.Cmd
+	deviceConn  net.Conn
+	messageConn net.Conn
 }
 
 func (svr *server) Start(opt *serverOption) (err error) {
@@ -63,6 +67,15 @@ func (svr *server) startOverTcp(opt *serverOption) error {
 func (svr *server) startOverUsb(opt *serverOption) (err error) {
 	svr.serverOption = *opt
 
+	if svr.localPort == 0 {
+		if svr.localPort, err = allocLocalPort(); err != nil {
+			return
+		}
+		if debugOpt.Debug() {
+			log.Printf("allocate local port %d for %q\n", svr.localPort, svr.serial)
+		}
+	}
+
 	if err = svr.pushRemote(); err != nil {
 		log.Printf("push server.jar fail: %v\n", err)
 		return
@@ -91,7 +104,8 @@ func (svr *server) startOverUsb(opt *serverOption) (err error) {
 	return
 }
 
-func (svr *server) ConnectTo() (err error) {
+// 建立视频连接并读取设备信息，服务端支持时再建立消息连接
+func (svr *server) ConnectTo() (deviceName string, screenSize size, caps capabilities, err error) {
 	if svr.overTcp || !svr.tunnelForward {
 		if svr.deviceConn, err = svr.listener.Accept(); err != nil {
 			return
@@ -101,6 +115,15 @@ func (svr *server) ConnectTo() (err error) {
 			return
 		}
 	}
+	if deviceName, screenSize, caps, err = svr.ReadDeviceInfo(); err != nil {
+		return
+	}
+	// 旧版本的服务端没有握手，也不会建立消息连接，不需要等待
+	if caps.version > 0 {
+		if err := svr.connectMessage(); err != nil {
+			log.Printf("device messages unavailable (%v); %s\n", err, svr.jarHint())
+		}
+	}
 
 	svr.stopListen()
 	if !svr.overTcp {
@@ -111,7 +134,7 @@ func (svr *server) ConnectTo() (err error) {
 	svr.disableTunnel()
 	svr.tunnelEnable = false
 
-	return nil
+	return
 }
 
 func (svr *server) Recv(buf []byte) (err error) {
@@ -131,8 +154,11 @@ func (svr *server) Send(buf []byte) (err error) {
 }
 
 func (svr *server) Stop() (err error) {
-	if err = svr.serverProc.Process.Kill(); err != nil {
-		log.Println(err)
+	// 局域网连接时服务端不由本进程启动
+	if svr.serverProc != nil {
+		if err = svr.serverProc.Process.Kill(); err != nil {
+			log.Println(err)
+		}
 	}
 
 	if svr.tunnelEnable {
@@ -155,6 +181,9 @@ func (svr *server) Close() error {
 	if svr.deviceConn != nil {
 		svr.deviceConn.Close()
 	}
+	if svr.messageConn != nil {
+		svr.messageConn.Close()
+	}
 	return nil
 }
 
@@ -219,7 +248,9 @@ func (svr *server) execute() (err error) {
 		className,
 		fmt.Sprintf("%d", svr.bitRate),
 		fmt.Sprintf("%v", svr.tunnelForward),
-		"false")
+		"false",
+		"true",
+		fmt.Sprintf("%d", protocolVersion))
 	return
 }
 
@@ -235,6 +266,35 @@ func (svr *server) stopListen() error {
 	return nil
 }
 
+// 设备端在发送设备信息之后通过同一个 tunnel 建立第二个连接，专门发送设备消息
+func (svr *server) connectMessage() (err error) {
+	if svr.overTcp || !svr.tunnelForward {
+		if l, ok := svr.listener.(*net.TCPListener); ok {
+			l.SetDeadline(time.Now().Add(messageConnTimeout))
+			defer l.SetDeadline(time.Time{})
+		}
+		svr.messageConn, err = svr.listener.Accept()
+		return
+	}
+
+	var conn net.Conn
+	if conn, err = dialAndReadByte(svr.localPort, messageConnTimeout); err != nil {
+		return
+	}
+	svr.messageConn = conn
+	return
+}
+
+// 多个设备同时连接时各自需要一个本地端口，由系统分配一个当前空闲的端口
+func allocLocalPort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
 func (svr *server) connectToRemote(attempts int, delay, timeout time.Duration) (err error) {
 	for attempts > 0 {
 		if err = svr.connectAndReadByte(timeout); err == nil {
@@ -248,21 +308,28 @@ func (svr *server) connectToRemote(attempts int, delay, timeout time.Duration) (
 }
 
 func (svr *server) connectAndReadByte(timeout time.Duration) (err error) {
-	if svr.deviceConn, err = net.Dial(
-		"tcp", fmt.Sprintf(":%d", svr.localPort)); err != nil {
+	svr.deviceConn, err = dialAndReadByte(svr.localPort, timeout)
+	return
+}
+
+func dialAndReadByte(port int, timeout time.Duration) (conn net.Conn, err error) {
+	if conn, err = net.Dial("tcp", fmt.Sprintf(":%d", port)); err != nil {
 		return
 	}
 
 	if timeout > 0 {
-		svr.deviceConn.SetReadDeadline(time.Now().Add(timeout))
-		defer svr.deviceConn.SetReadDeadline(time.Time{})
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		defer conn.SetReadDeadline(time.Time{})
 	}
 
 	// 只要 tunnel 建立（adb froward）建连就会成功，
 	// 即使此时 device 上的 server 还没有 listen。
 	// 所以这里还要读取一个字节，保证 device 上的 server 已经开始工作
 	buf := make([]byte, 1)
-	_, err = io.ReadFull(svr.deviceConn, buf)
+	if _, err = io.ReadFull(conn, buf); err != nil {
+		conn.Close()
+		return nil, err
+	}
 	return
 }
 
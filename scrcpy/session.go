@@ -0,0 +1,727 @@
+package scrcpy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ClarkGuan/go-sdl2/sdl"
+)
+
+const eventReconnected = sdl.USEREVENT + 7
+const eventReconnectFailed = sdl.USEREVENT + 8
+const eventConfigChanged = sdl.USEREVENT + 9
+const eventToastTimeout = sdl.USEREVENT + 10
+const eventForegroundChanged = sdl.USEREVENT + 11
+const eventDeviceMessage = sdl.USEREVENT + 13
+
+// 提示信息的显示时间
+const toastDuration = 3 * time.Second
+
+// 重连间隔逐次翻倍，最长不超过该值
+const maxReconnectDelay = 30 * time.Second
+
+// 一个设备对应一个 Session，拥有各自的服务端、解码器、控制器、手指分配以及窗口，
+// 多个 Session 可以在同一个进程中互不影响地运行
+type Session struct {
+	opt       *Option
+	localPort int
+
+	svr        *server
+	decoder    Decoder
+	conn       connWriter
+	controller Controller
+	fingers    fingerState
+	screen     screen
+	poster     eventPoster
+	handlers   []SdlEventHandler
+	fh         *frameHandler
+	ch         *controlHandler
+	filter     capabilityFilter
+	editor     *keyEditor
+	message    messageRenderer
+	toastTimer *time.Timer
+	receiver   messageReceiver
+
+	runner *commandRunner
+	api    *apiServer
+
+	deviceName string
+	screenSize size
+	caps       capabilities
+
+	// 当前使用的配置文件，按前台应用切换
+	configPath string
+	foreground string
+	// 未经换算的配置，屏幕方向变化时按新的视频帧重新选择按键映射
+	config      *Config
+	orientation Orientation
+
+	// 等待在 SDL 线程中处理的设备消息
+	deviceMessages []deviceMessage
+
+	// 断线重连
+	reconnects   int
+	reconnecting bool
+	pending      *deviceConnection
+	mutex        sync.Mutex
+
+	// 不显示画面时，视频流结束或者脚本执行完毕后关闭
+	done     chan struct{}
+	doneOnce sync.Once
+	err      error
+	closed   int32
+	closing  chan struct{}
+}
+
+// 一次成功的连接：服务端已启动并完成握手
+type deviceConnection struct {
+	svr        *server
+	deviceName string
+	screenSize size
+	caps       capabilities
+}
+
+func NewSession(opt *Option) *Session {
+	return &Session{opt: opt, localPort: opt.Port, configPath: opt.ConfigPath,
+		done: make(chan struct{}), closing: make(chan struct{})}
+}
+
+func (s *Session) Start() (err error) {
+	dc, err := s.connect()
+	if err != nil {
+		return
+	}
+	s.svr, s.deviceName, s.screenSize, s.caps = dc.svr, dc.deviceName, dc.screenSize, dc.caps
+	s.conn.set(s.svr.deviceConn)
+	s.filter.set(s.caps)
+	if debugOpt.Debug() {
+		log.Printf("device name: %s, screen %v, %v\n", s.deviceName, s.screenSize, s.caps)
+	}
+	config := s.opt.Config
+	s.config = &config
+	s.orientation = orientationOf(int(s.screenSize.width), int(s.screenSize.height))
+	s.checkConfigBounds(s.config)
+	s.opt.Config = *s.config.ForFrame(int(s.screenSize.width), int(s.screenSize.height))
+	s.fingers.configure(s.fingerLimit(), s.opt.FingerPolicy)
+	go s.fingers.watchLeaks(s.closing)
+	s.receiver.register(deviceMsgRotation, func(msg deviceMessage) {
+		if debugOpt.Debug() {
+			log.Printf("device rotation: %d\n", msg.(rotationMessage).rotation)
+		}
+	})
+	s.receiver.register(deviceMsgError, func(msg deviceMessage) {
+		log.Println("device error:", msg.(errorMessage).text)
+	})
+
+	var decoder Decoder
+	if decoder, err = s.newDecoder(); err != nil {
+		return
+	}
+	s.decoder = decoder
+
+	if s.opt.NoDisplay {
+		return s.startNoDisplay()
+	}
+	return s.startDisplay()
+}
+
+// 启动服务端并完成握手，失败时清理已经启动的部分
+func (s *Session) connect() (*deviceConnection, error) {
+	svr := new(server)
+	svrOpt := serverOption{serial: s.opt.Serial, localPort: s.localPort, bitRate: s.opt.BitRate, overTcp: s.opt.OverTcp}
+	if err := svr.Start(&svrOpt); err != nil {
+		return nil, err
+	}
+	// 自动分配的端口在重连时继续使用
+	s.localPort = svr.localPort
+
+	dc := deviceConnection{svr: svr}
+	var err error
+	if dc.deviceName, dc.screenSize, dc.caps, err = svr.ConnectTo(); err != nil {
+		svr.Stop()
+		svr.Close()
+		return nil, err
+	}
+	return &dc, nil
+}
+
+// 不超过服务端能注入的手指数量
+func (s *Session) fingerLimit() int {
+	limit := s.opt.MaxFingers
+	if limit <= 0 {
+		limit = DefaultMaxFingers
+	}
+	if s.caps.maxPointers > 0 && limit > s.caps.maxPointers {
+		log.Printf("max fingers %d exceeds what the device server supports, limited to %d\n", limit, s.caps.maxPointers)
+		limit = s.caps.maxPointers
+	}
+	return limit
+}
+
+func (s *Session) newDecoder() (Decoder, error) {
+	return newDecoder(&decoderOption{
+		name:       s.opt.Decoder,
+		recordPath: s.recordPath(),
+		frameSize:  s.screenSize,
+		noDisplay:  s.opt.NoDisplay,
+	}, s.svr.deviceConn)
+}
+
+// 重连后录像写入新的文件，避免覆盖之前的内容：a.mp4、a-1.mp4、a-2.mp4……
+func (s *Session) recordPath() string {
+	path := s.opt.RecordPath
+	if len(path) == 0 || s.reconnects == 0 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), s.reconnects, ext)
+}
+
+func (s *Session) startDisplay() (err error) {
+	if s.opt.AlwaysOnTop {
+		s.screen.windowFlags |= uint32(sdl.WINDOW_ALWAYS_ON_TOP)
+	}
+	if s.opt.Borderless {
+		s.screen.windowFlags |= uint32(sdl.WINDOW_BORDERLESS)
+	}
+	if s.opt.Fullscreen {
+		s.screen.windowFlags |= uint32(sdl.WINDOW_FULLSCREEN_DESKTOP)
+	}
+	if err = s.screen.InitRendering(s.deviceName, s.screenSize); err != nil {
+		return
+	}
+	var windowID uint32
+	if windowID, err = s.screen.window.GetID(); err != nil {
+		return
+	}
+	s.poster = eventPoster{windowID: windowID}
+	s.decoder.SetListener(sdlDecoderListener{poster: s.poster})
+
+	s.controller = newController(&s.conn, &s.screen)
+	s.controller.Start()
+
+	s.fh = &frameHandler{screen: &s.screen, decoder: s.decoder}
+	s.ch = newControlHandler(s.controller, &s.fingers, s.poster, s.opt)
+	s.ch.screen = &s.screen
+	s.ch.filter = &s.filter
+	s.ch.refresh = s.refresh
+	s.editor = &keyEditor{screen: &s.screen, refresh: s.refresh, path: s.currentConfigPath,
+		onEnter: s.ch.releaseAll, toast: s.showToast}
+	textInput := &textInputHandler{controller: s.controller, onEnter: s.ch.releaseAll, toast: s.showToast}
+	s.handlers = append(s.handlers, s.fh, &windowHandler{screen: &s.screen}, s.editor, textInput, s.ch)
+	s.screen.addRendererFunc(s.ch)
+	s.screen.addRendererFunc(s.editor)
+	s.screen.addRendererFunc(&s.message)
+	s.controller.Register(&s.filter)
+
+	if len(s.opt.ConfigPath) > 0 || s.opt.Profiles != nil {
+		go watchFile(s.currentConfigPath, time.Second, s.closing, func() {
+			s.poster.push(eventConfigChanged, 0)
+		})
+	}
+	if s.opt.Profiles != nil {
+		go watchForeground(s.opt.Serial, profilePollInterval, s.closing, func(pkg string) {
+			s.mutex.Lock()
+			s.foreground = pkg
+			s.mutex.Unlock()
+			s.poster.push(eventForegroundChanged, 0)
+		})
+	}
+
+	s.receiver.register(deviceMsgClipboard, s.postDeviceMessage)
+	s.receiver.register(deviceMsgError, s.postDeviceMessage)
+
+	if err = s.startCommands(); err != nil {
+		return
+	}
+	s.startMessages()
+	return s.decoder.Start()
+}
+
+// 不打开窗口：视频流只用于录像，控制事件来自脚本或者本地 API
+func (s *Session) startNoDisplay() (err error) {
+	s.decoder.SetListener(s.noDisplayListener())
+
+	s.screen.frameSize = s.screenSize
+	s.controller = newController(&s.conn, &s.screen)
+	s.controller.Register(&touchHandler{})
+	s.controller.Register(&s.filter)
+	s.controller.Start()
+
+	if err = s.startCommands(); err != nil {
+		return
+	}
+	s.startMessages()
+	return s.decoder.Start()
+}
+
+func (s *Session) noDisplayListener() DecoderListener {
+	return decoderStopListener(func() {
+		log.Println("Video stream stopped")
+		if s.opt.ReconnectRetries == 0 || s.isClosed() {
+			s.finish(nil)
+			return
+		}
+		// 回调在解码线程中，不能在这里等待解码器结束
+		go s.reconnectNoDisplay()
+	})
+}
+
+// 旧版本的服务端没有消息连接
+func (s *Session) startMessages() {
+	if s.svr.messageConn != nil {
+		go s.receiver.run(s.svr.messageConn)
+	}
+}
+
+// 在读取消息的 goroutine 中调用，转到 SDL 线程处理
+func (s *Session) postDeviceMessage(msg deviceMessage) {
+	s.mutex.Lock()
+	s.deviceMessages = append(s.deviceMessages, msg)
+	s.mutex.Unlock()
+	s.poster.push(eventDeviceMessage, 0)
+}
+
+func (s *Session) startCommands() (err error) {
+	s.runner = newCommandRunner(s.controller, &s.fingers)
+	if len(s.opt.ApiAddr) > 0 {
+		if s.api, err = startApiServer(s.opt.ApiAddr, s.runner); err != nil {
+			return
+		}
+	}
+	if len(s.opt.Script) > 0 {
+		go func() {
+			err := s.runner.runScriptFile(s.opt.Script)
+			if err != nil {
+				err = fmt.Errorf("script: %v", err)
+			}
+			if s.opt.NoDisplay {
+				s.finish(err)
+			} else if err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+	return
+}
+
+func (s *Session) finish(err error) {
+	s.doneOnce.Do(func() {
+		s.err = err
+		close(s.done)
+	})
+}
+
+func (s *Session) isClosed() bool {
+	return atomic.LoadInt32(&s.closed) != 0
+}
+
+// 断开旧连接并清空按键、手指状态
+func (s *Session) disconnect() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.conn.set(nil)
+	s.decoder.Stop()
+	if s.svr != nil {
+		s.svr.Stop()
+		s.svr.Close()
+		s.svr = nil
+	}
+
+	if s.ch != nil {
+		s.ch.reset()
+	} else {
+		s.controller.PushEvent(resetTouchEvent{})
+	}
+	s.runner.reset()
+	s.fingers.reset()
+}
+
+// 按照配置的次数和间隔重新启动服务端，ReconnectRetries 小于 0 时不限次数
+func (s *Session) reconnect() (*deviceConnection, error) {
+	delay := s.opt.ReconnectDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+
+	var err error
+	for i := 0; s.opt.ReconnectRetries < 0 || i < s.opt.ReconnectRetries; i++ {
+		time.Sleep(delay)
+		if s.isClosed() {
+			return nil, errors.New("session closed")
+		}
+
+		log.Printf("Reconnecting to %q (%d)\n", s.opt.Serial, i+1)
+		var dc *deviceConnection
+		if dc, err = s.connect(); err == nil {
+			return dc, nil
+		}
+		log.Println("reconnect:", err)
+
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+	return nil, fmt.Errorf("reconnect failed: %v", err)
+}
+
+// 用新的连接恢复视频流，控制器与按键映射保持不变
+func (s *Session) resume(dc *deviceConnection) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.isClosed() {
+		dc.svr.Stop()
+		dc.svr.Close()
+		return nil
+	}
+
+	s.reconnects++
+	s.svr, s.deviceName, s.screenSize, s.caps = dc.svr, dc.deviceName, dc.screenSize, dc.caps
+	s.filter.set(s.caps)
+	if s.opt.NoDisplay {
+		s.screen.frameSize = s.screenSize
+	}
+	s.conn.set(s.svr.deviceConn)
+
+	var decoder Decoder
+	if decoder, err = s.newDecoder(); err != nil {
+		return
+	}
+	s.decoder = decoder
+	if s.opt.NoDisplay {
+		s.decoder.SetListener(s.noDisplayListener())
+	} else {
+		s.decoder.SetListener(sdlDecoderListener{poster: s.poster})
+		s.fh.decoder = s.decoder
+	}
+	log.Printf("Reconnected to %q\n", s.opt.Serial)
+	s.startMessages()
+	return s.decoder.Start()
+}
+
+func (s *Session) reconnectNoDisplay() {
+	s.disconnect()
+	dc, err := s.reconnect()
+	if err == nil {
+		err = s.resume(dc)
+	}
+	if err != nil {
+		s.finish(err)
+	}
+}
+
+// 以下在 SDL 线程中调用
+
+func (s *Session) handleDecoderStopped() {
+	log.Println("Video decoder stopped")
+	if s.opt.ReconnectRetries == 0 || s.reconnecting {
+		return
+	}
+
+	s.reconnecting = true
+	s.showMessage("设备连接断开，正在重新连接……")
+	s.disconnect()
+	go func() {
+		dc, err := s.reconnect()
+		if err != nil {
+			log.Println(err)
+			s.poster.push(eventReconnectFailed, 0)
+			return
+		}
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.isClosed() {
+			dc.svr.Stop()
+			dc.svr.Close()
+			return
+		}
+		s.pending = dc
+		s.poster.push(eventReconnected, 0)
+	}()
+}
+
+func (s *Session) handleReconnected() error {
+	s.mutex.Lock()
+	dc := s.pending
+	s.pending = nil
+	s.mutex.Unlock()
+
+	s.reconnecting = false
+	s.showMessage("")
+	if err := s.resume(dc); err != nil {
+		return err
+	}
+	// 断开期间设备可能旋转了
+	s.handleFrameSizeChanged(s.screenSize)
+	return nil
+}
+
+func (s *Session) handleDeviceMessages() {
+	s.mutex.Lock()
+	msgs := s.deviceMessages
+	s.deviceMessages = nil
+	s.mutex.Unlock()
+
+	for _, msg := range msgs {
+		switch m := msg.(type) {
+		case clipboardMessage:
+			if err := sdl.SetClipboardText(m.text); err != nil {
+				log.Println("set clipboard:", err)
+				continue
+			}
+			s.showToast("已同步设备剪贴板")
+		case errorMessage:
+			s.showToast("设备端错误：" + m.text)
+		}
+	}
+}
+
+func (s *Session) currentConfigPath() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.configPath
+}
+
+// 前台应用变化时切换到对应的配置文件
+func (s *Session) switchProfile() {
+	s.mutex.Lock()
+	pkg := s.foreground
+	path := s.opt.Profiles.Path(pkg)
+	if len(path) == 0 {
+		path = s.opt.ConfigPath
+	}
+	if path == s.configPath {
+		s.mutex.Unlock()
+		return
+	}
+	s.configPath = path
+	s.mutex.Unlock()
+
+	log.Printf("Foreground %s, switch to %s\n", pkg, path)
+	if s.reloadConfig() {
+		s.showToast(fmt.Sprintf("%s：已切换到配置 %s", pkg, filepath.Base(path)))
+	}
+}
+
+// 重新加载配置文件，先松开所有按下的手指再替换按键映射
+func (s *Session) reloadConfig() bool {
+	path := s.currentConfigPath()
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		log.Println("reload config:", err)
+		if ce, ok := err.(*ConfigError); ok {
+			p := ce.Problems[0]
+			s.showToast(fmt.Sprintf("配置加载失败（共 %d 处）：第 %d 行 %s", len(ce.Problems), p.Line, p.Message))
+		} else {
+			s.showToast(fmt.Sprintf("配置加载失败：%v", err))
+		}
+		return false
+	}
+	s.checkConfigBounds(cfg)
+	s.config = cfg
+	s.applyConfig()
+	log.Println("Config reloaded:", path)
+	s.showToast("配置已重新加载")
+	return true
+}
+
+// 按当前的视频帧换算配置，先松开所有按下的手指再替换按键映射
+func (s *Session) applyConfig() {
+	frameSize := s.screen.frameSize
+	if frameSize.width == 0 || frameSize.height == 0 {
+		frameSize = s.screenSize
+	}
+	cfg := s.config.ForFrame(int(frameSize.width), int(frameSize.height))
+
+	s.ch.releaseAll()
+	s.runner.reset()
+	s.fingers.reset()
+	s.ch.applyConfig(cfg)
+}
+
+// 当前的屏幕方向
+func (s *Session) Orientation() Orientation {
+	return s.orientation
+}
+
+// 视频帧宽高变化说明设备旋转了，按新的方向重新选择按键映射，
+// 视角范围及方向键的几何位置在 applyConfig 中一起更新
+func (s *Session) handleFrameSizeChanged(frameSize size) {
+	o := orientationOf(int(frameSize.width), int(frameSize.height))
+	if o == s.orientation {
+		return
+	}
+	s.orientation = o
+	log.Printf("Orientation changed: %v (%dx%d)\n", o, frameSize.width, frameSize.height)
+
+	// 按键映射使用视频帧坐标，需要在换算之前更新画面大小
+	s.screen.prepareForFrame(frameSize)
+	s.applyConfig()
+	if _, ok := s.config.Orientations[o]; ok {
+		s.showToast(fmt.Sprintf("屏幕方向：%s，已切换按键映射", orientationLabels[o]))
+	} else {
+		s.showToast(fmt.Sprintf("屏幕方向：%s", orientationLabels[o]))
+	}
+}
+
+var orientationLabels = map[Orientation]string{
+	Portrait:  "竖屏",
+	Landscape: "横屏",
+}
+
+// 坐标超出设备画面时只给出警告。设备可能处于竖屏状态，两个方向都超出才算
+func (s *Session) checkConfigBounds(cfg *Config) {
+	w, h := int(s.screenSize.width), int(s.screenSize.height)
+	if w == 0 || h == 0 {
+		return
+	}
+	if err := cfg.CheckBounds(w, h); err != nil {
+		if cfg.CheckBounds(h, w) != nil {
+			if ce, ok := err.(*ConfigError); ok {
+				ce.Path = s.currentConfigPath()
+			}
+			log.Printf("warning: config points outside the device:\n%v\n", err)
+		}
+	}
+}
+
+// 显示一段时间后自动消失的提示
+func (s *Session) showToast(text string) {
+	s.showMessage(text)
+	if s.toastTimer != nil {
+		s.toastTimer.Stop()
+	}
+	s.toastTimer = time.AfterFunc(toastDuration, func() {
+		s.poster.push(eventToastTimeout, 0)
+	})
+}
+
+func (s *Session) showMessage(text string) {
+	s.message.text = text
+	s.refresh()
+}
+
+// 收到第一帧之后才能重新绘制
+func (s *Session) refresh() {
+	if s.screen.hasFrame {
+		s.screen.render()
+	}
+}
+
+// 手柄事件不属于任何窗口，由 gamepadRouter 交给获得焦点的 Session。编辑模式中忽略
+func (s *Session) handleGamepadEvent(event sdl.Event) error {
+	if s.isClosed() || s.ch == nil || s.editor.active {
+		return nil
+	}
+	_, err := s.ch.HandleSdlEvent(event)
+	return err
+}
+
+func (s *Session) handleGamepadRemoved() {
+	if !s.isClosed() && s.ch != nil {
+		s.ch.releaseGamepad()
+	}
+}
+
+// 只处理属于自己窗口的事件
+func (s *Session) HandleSdlEvent(event sdl.Event) (bool, error) {
+	if id, ok := eventWindowID(event); !ok || id != s.poster.windowID {
+		return false, nil
+	}
+	if s.isClosed() {
+		return true, nil
+	}
+
+	switch event.GetType() {
+	case sdl.WINDOWEVENT:
+		if event.(*sdl.WindowEvent).Event == sdl.WINDOWEVENT_CLOSE {
+			// 只关闭这一个设备，最后一个窗口关闭时 SDL 会发送 SDL_QUIT
+			return true, s.Close()
+		}
+
+	case eventDecoderStopped:
+		s.handleDecoderStopped()
+		return true, nil
+
+	case eventReconnected:
+		return true, s.handleReconnected()
+
+	case eventReconnectFailed:
+		s.reconnecting = false
+		s.showMessage("重新连接失败")
+		return true, nil
+
+	case eventConfigChanged:
+		s.reloadConfig()
+
+	case eventForegroundChanged:
+		s.switchProfile()
+		return true, nil
+
+	case eventDeviceMessage:
+		s.handleDeviceMessages()
+		return true, nil
+
+	case eventFrameSizeChanged:
+		code := event.(*sdl.UserEvent).Code
+		s.handleFrameSizeChanged(size{width: uint16(code >> 16), height: uint16(code & 0xffff)})
+
+	case eventToastTimeout:
+		if !s.reconnecting {
+			s.showMessage("")
+		}
+		return true, nil
+	}
+
+	for _, h := range s.handlers {
+		if b, err := h.HandleSdlEvent(event); err != nil || b {
+			return b, err
+		}
+	}
+	return true, nil
+}
+
+func (s *Session) Close() error {
+	if !atomic.CompareAndSwapInt32(&s.closed, 0, 1) {
+		return nil
+	}
+	close(s.closing)
+	if s.toastTimer != nil {
+		s.toastTimer.Stop()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.api != nil {
+		s.api.Close()
+	}
+	if s.runner != nil {
+		s.runner.releaseAll()
+	}
+	// 已经推送的事件处理完后结束 controller 的 goroutine
+	if s.controller != nil {
+		s.controller.Stop()
+	}
+	if s.decoder != nil {
+		s.decoder.Stop()
+	}
+	if s.svr != nil {
+		s.svr.Stop()
+		s.svr.Close()
+	}
+	if s.pending != nil {
+		s.pending.svr.Stop()
+		s.pending.svr.Close()
+		s.pending = nil
+	}
+	return s.screen.Close()
+}
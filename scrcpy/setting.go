@@ -23,6 +23,40 @@ var MouseButtonMap = map[string]uint8{
 	BUTTON_X2:     sdl.BUTTON_X2,
 }
 
+// 手柄的两个扳机没有对应的按键，按下超过一半时视为按下
+const (
+	gamepadLeftTrigger = sdl.CONTROLLER_BUTTON_MAX + iota
+	gamepadRightTrigger
+)
+
+var GamepadButtonMap = map[string]int{
+	"GAMEPAD_A":             sdl.CONTROLLER_BUTTON_A,
+	"GAMEPAD_B":             sdl.CONTROLLER_BUTTON_B,
+	"GAMEPAD_X":             sdl.CONTROLLER_BUTTON_X,
+	"GAMEPAD_Y":             sdl.CONTROLLER_BUTTON_Y,
+	"GAMEPAD_BACK":          sdl.CONTROLLER_BUTTON_BACK,
+	"GAMEPAD_GUIDE":         sdl.CONTROLLER_BUTTON_GUIDE,
+	"GAMEPAD_START":         sdl.CONTROLLER_BUTTON_START,
+	"GAMEPAD_LEFTSTICK":     sdl.CONTROLLER_BUTTON_LEFTSTICK,
+	"GAMEPAD_RIGHTSTICK":    sdl.CONTROLLER_BUTTON_RIGHTSTICK,
+	"GAMEPAD_LEFTSHOULDER":  sdl.CONTROLLER_BUTTON_LEFTSHOULDER,
+	"GAMEPAD_RIGHTSHOULDER": sdl.CONTROLLER_BUTTON_RIGHTSHOULDER,
+	"GAMEPAD_DPAD_UP":       sdl.CONTROLLER_BUTTON_DPAD_UP,
+	"GAMEPAD_DPAD_DOWN":     sdl.CONTROLLER_BUTTON_DPAD_DOWN,
+	"GAMEPAD_DPAD_LEFT":     sdl.CONTROLLER_BUTTON_DPAD_LEFT,
+	"GAMEPAD_DPAD_RIGHT":    sdl.CONTROLLER_BUTTON_DPAD_RIGHT,
+	"GAMEPAD_LEFTTRIGGER":   gamepadLeftTrigger,
+	"GAMEPAD_RIGHTTRIGGER":  gamepadRightTrigger,
+}
+
+// 相对鼠标模式下滚轮拖动的按键映射（type: wheel），值为需要同时按住的修饰键
+var WheelCodeMap = map[string]int{
+	"WHEEL":       0,
+	"SHIFT+WHEEL": sdl.KMOD_SHIFT,
+	"CTRL+WHEEL":  sdl.KMOD_CTRL,
+	"ALT+WHEEL":   sdl.KMOD_ALT,
+}
+
 var KeyCodeConstMap = map[string]int{
 	SCRCPY_FIRE:               FireKeyCode,
 	SCRCPY_VISION_TOPLEFT:     VisionBoundTopLeft,
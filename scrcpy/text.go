@@ -0,0 +1,58 @@
+package scrcpy
+
+import (
+	"encoding/binary"
+	"io"
+	"unicode/utf8"
+)
+
+// 与服务端 ControlEventReader.TEXT_MAX_LENGTH 一致，超出的部分需要拆成多个事件
+const textMaxLength = 300
+
+// 向设备当前获得焦点的输入框输入文字
+type textEvent struct {
+	text string
+}
+
+func (te *textEvent) EventType() controlEventType {
+	return CONTROL_EVENT_TYPE_TEXT
+}
+
+func (te *textEvent) Serialize(w io.Writer, data ...interface{}) error {
+	buf := make([]byte, 3+len(te.text))
+	buf[0] = byte(te.EventType())
+	binary.BigEndian.PutUint16(buf[1:], uint16(len(te.text)))
+	copy(buf[3:], te.text)
+	_, err := w.Write(buf)
+	return err
+}
+
+// 按 UTF-8 字符边界拆分，每段不超过 max 个字节
+func splitText(text string, max int) []string {
+	var parts []string
+	for len(text) > max {
+		n := max
+		for n > 0 && !utf8.RuneStart(text[n]) {
+			n--
+		}
+		if n == 0 {
+			// 不是合法的 UTF-8，只能强行截断
+			n = max
+		}
+		parts = append(parts, text[:n])
+		text = text[n:]
+	}
+	if len(text) > 0 {
+		parts = append(parts, text)
+	}
+	return parts
+}
+
+func pushText(c Controller, text string) error {
+	for _, part := range splitText(text, textMaxLength) {
+		if err := c.PushEvent(&textEvent{text: part}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,7 +1,14 @@
 package scrcpy
 
 import (
+	"fmt"
 	"io"
+	"log"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 )
 
 // touch pointer 规则：
@@ -90,6 +97,23 @@ func (set *mouseEventSet) accept(se *singleMouseEvent) {
 	set.id = se.id
 }
 
+func (set *mouseEventSet) reset() {
+	set.points = set.points[:0]
+}
+
+// 依次松开所有按下的手指
+func (set *mouseEventSet) releaseAll(w io.Writer, data ...interface{}) error {
+	for len(set.points) > 0 {
+		se := singleMouseEvent{action: AMOTION_EVENT_ACTION_UP}
+		se.touchPoint = set.points[len(set.points)-1]
+		set.accept(&se)
+		if err := set.Serialize(w, data...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (set *mouseEventSet) Serialize(w io.Writer, data ...interface{}) error {
 	if set.buf == nil {
 		set.buf = make([]byte, 0, 128)
@@ -144,20 +168,285 @@ type singleMouseEvent struct {
 	action androidMotionEventAction
 }
 
-type fingerState [8]bool
+// 设备上同时按下的手指数量已满时的处理方式
+type FingerPolicy int
+
+const (
+	// 放弃这次按下
+	FingerReject FingerPolicy = iota
+	// 抢占按下时间最早的宏手指，没有宏手指时放弃
+	FingerStealMacro
+	// 宏、脚本及本地 API 等待其他手指松开，超时后放弃；键盘、鼠标、手柄在 SDL 线程中按下，不等待
+	FingerQueue
+)
+
+var fingerPolicyNames = []string{"reject", "steal", "queue"}
+
+func ParseFingerPolicy(s string) (FingerPolicy, error) {
+	for i, name := range fingerPolicyNames {
+		if name == s {
+			return FingerPolicy(i), nil
+		}
+	}
+	return FingerReject, fmt.Errorf("unknown finger policy %q (reject, steal or queue)", s)
+}
+
+func (p FingerPolicy) String() string {
+	if p >= 0 && int(p) < len(fingerPolicyNames) {
+		return fingerPolicyNames[p]
+	}
+	return fmt.Sprintf("FingerPolicy(%d)", int(p))
+}
+
+const (
+	DefaultMaxFingers = 10
+	// Android 的 pointer id 范围是 0~31
+	MaxFingersLimit = 32
+
+	// 排队等待的时间不能太长，否则宏的时序会被打乱
+	fingerQueueTimeout = 200 * time.Millisecond
+	// 按下超过该时间仍未松开的手指视为可能泄漏
+	fingerLeakAge       = 2 * time.Minute
+	fingerCheckInterval = 10 * time.Second
+)
+
+type finger struct {
+	id     *int
+	since  time.Time
+	caller string
+	// 宏手指被抢占时调用，由宏负责向设备发送 UP
+	steal    func(id *int)
+	reported bool
+}
+
+type fingerWaiter struct {
+	ch     chan *int
+	caller string
+	steal  func(id *int)
+}
+
+// 每个设备（Session）各自分配手指 id
+type fingerState struct {
+	limit   int
+	policy  FingerPolicy
+	fingers []*finger
+	waiters []*fingerWaiter
+	// 已经记录过手指用完的日志
+	exhausted bool
+	// 手指可能同时被 SDL 线程、宏定时器以及本地 API 申请
+	mutex sync.Mutex
+}
 
-var fingers fingerState
+// limit 不大于 0 时使用默认值
+func (f *fingerState) configure(limit int, policy FingerPolicy) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
 
+	f.policy = policy
+	f.init(limit)
+}
+
+func (f *fingerState) init(limit int) {
+	if limit <= 0 {
+		limit = DefaultMaxFingers
+	} else if limit > MaxFingersLimit {
+		limit = MaxFingersLimit
+	}
+	f.limit = limit
+	f.fingers = make([]*finger, limit)
+	f.waiters = nil
+}
+
+// 手指数量已满并且无法按照 policy 得到手指时返回 nil，调用者应放弃这次按下。
+// 在 SDL 线程中调用，FingerQueue 时也不等待：松开手指的按键事件同样在 SDL 线程中处理
 func (f *fingerState) GetId() *int {
-	for i := range f[:] {
-		if !f[i] {
-			f[i] = true
-			return &i
+	return f.get(nil, false)
+}
+
+// 脚本及本地 API 在自己的 goroutine 中执行，FingerQueue 时可以等待
+func (f *fingerState) waitId() *int {
+	return f.get(nil, true)
+}
+
+// 宏按下的手指可以在手指不够用时被抢占。宏在自己的 goroutine 中执行，FingerQueue 时可以等待
+func (f *fingerState) getMacroId(steal func(id *int)) *int {
+	return f.get(steal, true)
+}
+
+func (f *fingerState) get(steal func(id *int), wait bool) *int {
+	caller := fingerCaller()
+	f.mutex.Lock()
+	if f.fingers == nil {
+		f.init(0)
+	}
+	for i := range f.fingers {
+		if f.fingers[i] == nil {
+			id := f.take(i, caller, steal)
+			f.mutex.Unlock()
+			return id
 		}
 	}
-	panic("finger number over 8")
+
+	switch f.policy {
+	case FingerStealMacro:
+		var victim *finger
+		for _, fg := range f.fingers {
+			if fg.steal != nil && (victim == nil || fg.since.Before(victim.since)) {
+				victim = fg
+			}
+		}
+		if victim != nil {
+			id := f.take(*victim.id, caller, steal)
+			f.mutex.Unlock()
+			log.Printf("finger %d stolen from macro (%s) by %s\n", *id, victim.caller, caller)
+			// 在锁外调用，宏在发送 UP 时可能正在申请或回收手指
+			victim.steal(victim.id)
+			return id
+		}
+
+	case FingerQueue:
+		if !wait {
+			break
+		}
+		w := &fingerWaiter{ch: make(chan *int, 1), caller: caller, steal: steal}
+		f.waiters = append(f.waiters, w)
+		f.mutex.Unlock()
+		select {
+		case id := <-w.ch:
+			return id
+		case <-time.After(fingerQueueTimeout):
+		}
+		f.mutex.Lock()
+		for i := range f.waiters {
+			if f.waiters[i] == w {
+				f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+				break
+			}
+		}
+		// 超时的同时可能刚好分配到了手指
+		select {
+		case id := <-w.ch:
+			f.mutex.Unlock()
+			return id
+		default:
+		}
+	}
+	// 方向键等会不断重试，直到有手指松开前只记录一次
+	if !f.exhausted {
+		f.exhausted = true
+		log.Printf("all %d fingers are in use, press from %s ignored\n", f.limit, caller)
+	}
+	f.mutex.Unlock()
+	return nil
 }
 
+func (f *fingerState) take(i int, caller string, steal func(id *int)) *int {
+	id := i
+	f.fingers[i] = &finger{id: &id, since: time.Now(), caller: caller, steal: steal}
+	return &id
+}
+
+// 同一个 id 可能已经被回收后再次分配给别人，所以按指针比较，过期的回收请求直接忽略
 func (f *fingerState) Recycle(i *int) {
-	f[*i] = false
+	if i == nil {
+		return
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if *i < 0 || *i >= len(f.fingers) || f.fingers[*i] == nil || f.fingers[*i].id != i {
+		log.Printf("finger %d recycled twice or not allocated (%s)\n", *i, fingerCaller())
+		return
+	}
+	if len(f.waiters) > 0 {
+		w := f.waiters[0]
+		f.waiters = f.waiters[1:]
+		w.ch <- f.take(*i, w.caller, w.steal)
+		return
+	}
+	f.fingers[*i] = nil
+	f.exhausted = false
+}
+
+func (f *fingerState) reset() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for i := range f.fingers {
+		f.fingers[i] = nil
+	}
+	f.exhausted = false
+}
+
+// 返回按下超过 maxAge 的手指，每个手指只报告一次
+func (f *fingerState) leaks(now time.Time, maxAge time.Duration) []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var ret []string
+	for _, fg := range f.fingers {
+		if fg != nil && !fg.reported && now.Sub(fg.since) >= maxAge {
+			fg.reported = true
+			ret = append(ret, fmt.Sprintf("finger %d held for %s, allocated by %s",
+				*fg.id, now.Sub(fg.since).Truncate(time.Second), fg.caller))
+		}
+	}
+	return ret
+}
+
+func (f *fingerState) watchLeaks(stop <-chan struct{}) {
+	ticker := time.NewTicker(fingerCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			for _, l := range f.leaks(now, fingerLeakAge) {
+				log.Printf("possible finger leak: %s\n", l)
+			}
+		}
+	}
+}
+
+// 记录申请手指的位置，跳过 fingerState 自身的调用
+func fingerCaller() string {
+	for skip := 1; ; skip++ {
+		pc, file, line, ok := runtime.Caller(skip)
+		if !ok {
+			return "unknown"
+		}
+		fn := runtime.FuncForPC(pc)
+		if fn != nil && strings.Contains(fn.Name(), "fingerState") {
+			continue
+		}
+		return fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+}
+
+// 通知 controller 线程清空多点触摸状态，release 为 true 时先向设备发送 UP 事件
+type resetTouchEvent struct {
+	release bool
+}
+
+// 不显示画面时没有 controlHandler，由它负责把单点事件合并成多点触摸事件
+type touchHandler struct {
+	set mouseEventSet
+}
+
+func (th *touchHandler) HandleControlEvent(c Controller, ent interface{}) interface{} {
+	if sme, ok := ent.(*singleMouseEvent); ok {
+		th.set.accept(sme)
+		return &th.set
+	}
+	if e, ok := ent.(resetTouchEvent); ok {
+		if e.release {
+			th.set.releaseAll(c.Writer(), c.Data()...)
+		} else {
+			th.set.reset()
+		}
+		return nil
+	}
+	return ent
 }
@@ -0,0 +1,214 @@
+package scrcpy
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFingerStateLimit(t *testing.T) {
+	var f fingerState
+	f.configure(12, FingerReject)
+
+	var ids []*int
+	for i := 0; i < 12; i++ {
+		id := f.GetId()
+		if id == nil {
+			t.Fatalf("finger %d: got nil", i)
+		}
+		if *id != i {
+			t.Errorf("finger %d: got id %d", i, *id)
+		}
+		ids = append(ids, id)
+	}
+	if id := f.GetId(); id != nil {
+		t.Fatalf("over limit: got id %d, want nil", *id)
+	}
+
+	// 松开中间的手指后复用它的 id
+	f.Recycle(ids[5])
+	if id := f.GetId(); id == nil || *id != 5 {
+		t.Fatalf("after recycle: got %v, want 5", id)
+	}
+}
+
+func TestFingerStateDefaultLimit(t *testing.T) {
+	var f fingerState
+	for i := 0; i < DefaultMaxFingers; i++ {
+		if f.GetId() == nil {
+			t.Fatalf("finger %d: got nil", i)
+		}
+	}
+	if f.GetId() != nil {
+		t.Fatal("over default limit: want nil")
+	}
+
+	f.configure(100, FingerReject)
+	if f.limit != MaxFingersLimit {
+		t.Errorf("limit = %d, want %d", f.limit, MaxFingersLimit)
+	}
+}
+
+func TestFingerStateStaleRecycle(t *testing.T) {
+	var f fingerState
+	f.configure(2, FingerReject)
+
+	old := f.GetId()
+	f.Recycle(old)
+	id := f.GetId()
+	if *id != *old {
+		t.Fatalf("got id %d, want %d", *id, *old)
+	}
+	// 重复回收不能释放已经分配给别人的手指
+	f.Recycle(old)
+	f.GetId()
+	if f.GetId() != nil {
+		t.Fatal("stale recycle freed a finger in use")
+	}
+}
+
+func TestFingerStateStealMacro(t *testing.T) {
+	var f fingerState
+	f.configure(3, FingerStealMacro)
+
+	var stolen []int
+	steal := func(id *int) { stolen = append(stolen, *id) }
+	f.GetId()
+	first := f.getMacroId(steal)
+	time.Sleep(time.Millisecond)
+	f.getMacroId(steal)
+
+	id := f.GetId()
+	if id == nil || *id != *first {
+		t.Fatalf("got %v, want the oldest macro finger %d", id, *first)
+	}
+	if len(stolen) != 1 || stolen[0] != *first {
+		t.Fatalf("stolen = %v, want [%d]", stolen, *first)
+	}
+	// 被抢占的宏之后回收自己的手指时应当被忽略
+	f.Recycle(first)
+	f.getMacroId(steal)
+	if f.fingers[*id].id != id {
+		t.Fatal("recycle by the robbed macro freed the new owner's finger")
+	}
+
+	// 没有宏手指时放弃
+	f.configure(1, FingerStealMacro)
+	f.GetId()
+	if f.GetId() != nil {
+		t.Fatal("no macro finger to steal: want nil")
+	}
+}
+
+func TestFingerStateQueue(t *testing.T) {
+	var f fingerState
+	f.configure(1, FingerQueue)
+
+	held := f.GetId()
+	// SDL 线程中的按下不等待
+	start := time.Now()
+	if f.GetId() != nil {
+		t.Fatal("GetId: want nil")
+	}
+	if elapsed := time.Since(start); elapsed >= fingerQueueTimeout {
+		t.Errorf("GetId waited %s", elapsed)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		f.Recycle(held)
+	}()
+	id := f.waitId()
+	if id == nil || *id != *held {
+		t.Fatalf("got %v, want the recycled finger %d", id, *held)
+	}
+
+	start = time.Now()
+	if f.waitId() != nil {
+		t.Fatal("queue timeout: want nil")
+	}
+	if elapsed := time.Since(start); elapsed < fingerQueueTimeout {
+		t.Errorf("gave up after %s, want %s", elapsed, fingerQueueTimeout)
+	}
+	if len(f.waiters) != 0 {
+		t.Errorf("%d waiters left after timeout", len(f.waiters))
+	}
+}
+
+func TestFingerStateLeaks(t *testing.T) {
+	var f fingerState
+	f.configure(4, FingerReject)
+
+	leaked := f.GetId()
+	now := time.Now()
+	if l := f.leaks(now, time.Minute); len(l) != 0 {
+		t.Fatalf("leaks = %v, want none", l)
+	}
+
+	l := f.leaks(now.Add(2*time.Minute), time.Minute)
+	if len(l) != 1 || !strings.Contains(l[0], "touch_test.go") {
+		t.Fatalf("leaks = %v, want one leak allocated by touch_test.go", l)
+	}
+	// 每个手指只报告一次
+	if l := f.leaks(now.Add(3*time.Minute), time.Minute); len(l) != 0 {
+		t.Fatalf("leaks reported twice: %v", l)
+	}
+
+	f.Recycle(leaked)
+	f.GetId()
+	f.reset()
+	if l := f.leaks(now.Add(time.Hour), time.Minute); len(l) != 0 {
+		t.Fatalf("leaks after reset = %v, want none", l)
+	}
+}
+
+func TestParseFingerPolicy(t *testing.T) {
+	for _, p := range []FingerPolicy{FingerReject, FingerStealMacro, FingerQueue} {
+		got, err := ParseFingerPolicy(p.String())
+		if err != nil || got != p {
+			t.Errorf("ParseFingerPolicy(%q) = %v, %v", p.String(), got, err)
+		}
+	}
+	if _, err := ParseFingerPolicy("drop"); err == nil {
+		t.Error("ParseFingerPolicy(\"drop\"): want error")
+	}
+}
+
+func TestMacroStealLockOrder(t *testing.T) {
+	var f fingerState
+	f.configure(1, FingerStealMacro)
+	rc := &recordController{}
+	macro := []*PointMacro{{Point: Point{1, 1}}}
+
+	victim := newControllerAnimation(rc, &f, macro)
+	victim.inProgress(nil)
+	// 被抢占的宏正在执行自己的一步
+	victim.mutex.Lock()
+
+	thief := newControllerAnimation(rc, &f, macro)
+	stepped := make(chan struct{})
+	go func() {
+		thief.inProgress(nil)
+		close(stepped)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// 抢占方等待被抢占的宏时不能持有自己的锁，否则双方互相抢占时会死锁
+	free := make(chan struct{})
+	go func() {
+		thief.mutex.Lock()
+		thief.mutex.Unlock()
+		close(free)
+	}()
+	select {
+	case <-free:
+	case <-time.After(time.Second):
+		t.Fatal("thief holds its own lock while waiting for the victim")
+	}
+
+	victim.mutex.Unlock()
+	<-stepped
+	if thief.id == nil || victim.id != nil {
+		t.Fatalf("thief id %v, victim id %v: want the finger moved to the thief", thief.id, victim.id)
+	}
+}
@@ -9,9 +9,6 @@ import (
 
 const DefaultMouseSensitive = .085
 
-// 鼠标精度控制
-var mouseSensitive = DefaultMouseSensitive
-
 // 自动释放手势时间间隔
 const mouseVisionDelay = time.Millisecond * 500
 
@@ -21,8 +18,12 @@ const eventVisionEventUp = sdl.USEREVENT + 3
 // 视野控制器
 type visionController struct {
 	controller  Controller
+	fingers     *fingerState
+	poster      eventPoster
 	topLeft     Point
 	bottomRight Point
+	// 鼠标精度控制
+	sensitive float64
 
 	center     *Point
 	cachePoint Point
@@ -30,14 +31,24 @@ type visionController struct {
 	timer      *time.Timer
 }
 
-func newVisionController(controller Controller, topLeft, bottomRight *Point) *visionController {
+func newVisionController(controller Controller, fingers *fingerState, poster eventPoster,
+	sensitive float64, topLeft, bottomRight *Point) *visionController {
 	return &visionController{
 		controller:  controller,
+		fingers:     fingers,
+		poster:      poster,
 		topLeft:     *topLeft,
 		bottomRight: *bottomRight,
+		sensitive:   sensitive,
 	}
 }
 
+func (v *visionController) setBounds(topLeft, bottomRight *Point) {
+	v.topLeft = *topLeft
+	v.bottomRight = *bottomRight
+	v.center = nil
+}
+
 func (v *visionController) outside(p *Point) bool {
 	ret := false
 	minW := uint16(v.topLeft.X)
@@ -73,9 +84,9 @@ func (v *visionController) getVisionCenterPoint() *Point {
 	return v.center
 }
 
-func fixMouseBlock(x int32) int32 {
+func (v *visionController) fixMouseBlock(x int32) int32 {
 	fx := float64(x)
-	ret := int32(fx*mouseSensitive + .5)
+	ret := int32(fx*v.sensitive + .5)
 	if ret == 0 && x != 0 {
 		if x > 0 {
 			ret = 1
@@ -98,7 +109,7 @@ func (v *visionController) sendEventDelay(duration time.Duration) {
 		v.timer.Reset(duration)
 	} else {
 		v.timer = time.AfterFunc(duration, func() {
-			sdl.PushEvent(&sdl.UserEvent{Type: eventVisionEventUp})
+			v.poster.push(eventVisionEventUp, 0)
 		})
 	}
 }
@@ -123,7 +134,7 @@ func (v *visionController) fingerUp() {
 	v.stopEventDelay()
 	if v.id != nil {
 		v.sendMouseEvent(AMOTION_EVENT_ACTION_UP, *v.id, v.cachePoint)
-		fingers.Recycle(v.id)
+		v.fingers.Recycle(v.id)
 		v.id = nil
 		if debugOpt.Info() {
 			log.Println("视角控制，松开，点：", v.cachePoint)
@@ -131,9 +142,16 @@ func (v *visionController) fingerUp() {
 	}
 }
 
+func (v *visionController) reset() {
+	v.stopEventDelay()
+	v.id = nil
+}
+
 func (v *visionController) fingerDown() {
 	if v.id == nil {
-		v.id = fingers.GetId()
+		if v.id = v.fingers.GetId(); v.id == nil {
+			return
+		}
 		v.cachePoint = *v.getVisionCenterPoint()
 		v.sendEventDelay(mouseVisionDelay)
 		if debugOpt.Info() {
@@ -145,8 +163,8 @@ func (v *visionController) fingerDown() {
 
 func (v *visionController) fingerMove(x, y int32, accurate bool) {
 	if !accurate {
-		x = fixMouseBlock(x)
-		y = fixMouseBlock(y)
+		x = v.fixMouseBlock(x)
+		y = v.fixMouseBlock(y)
 	}
 	v.cachePoint.X = uint16(int32(v.cachePoint.X) + x)
 	v.cachePoint.Y = uint16(int32(v.cachePoint.Y) + y)
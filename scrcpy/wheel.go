@@ -0,0 +1,131 @@
+package scrcpy
+
+import (
+	"math"
+	"time"
+
+	"github.com/ClarkGuan/go-sdl2/sdl"
+)
+
+const (
+	// 设备上的像素，不随 resolution 换算
+	defaultWheelStep  = 10
+	defaultWheelDelay = 150 * time.Millisecond
+)
+
+// 配置文件中 type: wheel 的 wheel 字段，坐标与 point 的单位相同
+type EntryWheel struct {
+	// y（默认）或 x
+	Axis string   `yaml:"axis"`
+	Step float64  `yaml:"step"`
+	Min  float64  `yaml:"min"`
+	Max  *float64 `yaml:"max"`
+	// 毫秒
+	Delay *int `yaml:"delay"`
+}
+
+// 游戏模式下的滚轮拖动：从 Point 按下，每滚动一格沿坐标轴移动 Step（向上、向右滚动时坐标增大，
+// Step 为负数时相反，为 0 时使用 defaultWheelStep），拖动范围为 Min~Max（Max 小于 0 时到画面边缘），停止滚动 Delay 之后松开
+type WheelDrag struct {
+	Point
+	Horizontal bool
+	Step       float64
+	Min        float64
+	Max        float64
+	Delay      time.Duration
+}
+
+// 拖动开始时在坐标轴上的位置
+func (wd *WheelDrag) start() float64 {
+	if wd.Horizontal {
+		return float64(wd.X)
+	}
+	return float64(wd.Y)
+}
+
+// 拖动范围，不超出画面
+func (wd *WheelDrag) bounds(frame size) (float64, float64) {
+	length := float64(frame.height)
+	if wd.Horizontal {
+		length = float64(frame.width)
+	}
+	max := wd.Max
+	if max < 0 || max > length-1 {
+		max = length - 1
+	}
+	return wd.Min, max
+}
+
+// 滚动 notches 格之后的位置
+func (wd *WheelDrag) move(v float64, notches int32, frame size) float64 {
+	min, max := wd.bounds(frame)
+	step := wd.Step
+	if step == 0 {
+		step = defaultWheelStep
+	}
+	return math.Max(min, math.Min(v+float64(notches)*step, max))
+}
+
+func (wd *WheelDrag) at(v float64) Point {
+	p := wd.Point
+	if wd.Horizontal {
+		p.X = uint16(math.Round(v))
+	} else {
+		p.Y = uint16(math.Round(v))
+	}
+	return p
+}
+
+// 按住的修饰键选择滚轮拖动，按住的修饰键没有单独配置时使用 WHEEL
+func (ch *controlHandler) wheelBinding() *WheelDrag {
+	mod := int(sdl.GetModState())
+	for _, m := range []int{sdl.KMOD_CTRL, sdl.KMOD_ALT, sdl.KMOD_SHIFT} {
+		if mod&m == 0 {
+			continue
+		}
+		if wd, ok := ch.wheelKeyMap[m].(*WheelDrag); ok {
+			return wd
+		}
+	}
+	wd, _ := ch.wheelKeyMap[0].(*WheelDrag)
+	return wd
+}
+
+func (ch *controlHandler) handleWheelDrag(x, y int32) (bool, error) {
+	if ch.keyState[wheelKeyCode] == nil {
+		wd := ch.wheelBinding()
+		if wd == nil {
+			return true, nil
+		}
+		id := ch.fingers.GetId()
+		if id == nil {
+			return true, nil
+		}
+		ch.keyState[wheelKeyCode] = id
+		ch.wheelDrag = wd
+		ch.wheelPosition = wd.start()
+		ch.wheelCachePointer = wd.Point
+		ch.sendEventDelay(eventWheelEvent, wd.Delay)
+		return ch.sendMouseEvent(AMOTION_EVENT_ACTION_DOWN, *id, ch.wheelCachePointer)
+	}
+
+	// 拖动过程中按下或松开修饰键不影响正在进行的拖动
+	wd := ch.wheelDrag
+	notches := y
+	if wd.Horizontal {
+		notches += x
+	}
+	ch.wheelPosition = wd.move(ch.wheelPosition, notches, ch.screen.frameSize)
+	ch.wheelCachePointer = wd.at(ch.wheelPosition)
+	ch.sendEventDelay(eventWheelEvent, wd.Delay)
+	return ch.sendMouseEvent(AMOTION_EVENT_ACTION_MOVE, *ch.keyState[wheelKeyCode], ch.wheelCachePointer)
+}
+
+func wheelCodeName(mod int) string {
+	for name, m := range WheelCodeMap {
+		if m == mod {
+			return name
+		}
+	}
+	return "WHEEL"
+}
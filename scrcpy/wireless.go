@@ -0,0 +1,73 @@
+package scrcpy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+const DefaultWirelessPort = 5555
+
+// 将通过 USB 连接的设备切换为无线调试，返回新的序列号（ip:port）。
+// 之后的推送、启动服务端以及端口转发都经由网络进行，可以拔掉 USB 线。
+func SetupWireless(serial string, port int) (string, error) {
+	ip, err := deviceWifiIP(serial)
+	if err != nil {
+		return "", err
+	}
+	if debugOpt.Info() {
+		log.Printf("device %q wifi address: %s\n", serial, ip)
+	}
+
+	if err = adbTcpip(serial, port); err != nil {
+		return "", err
+	}
+
+	// adbd 切换到 tcp 模式需要重启，稍等片刻再连接
+	addr := net.JoinHostPort(ip, fmt.Sprintf("%d", port))
+	for i := 0; i < 10; i++ {
+		time.Sleep(500 * time.Millisecond)
+		if err = adbConnect(addr); err == nil {
+			return addr, nil
+		}
+	}
+	return "", err
+}
+
+func deviceWifiIP(serial string) (string, error) {
+	out, err := adbOutput(serial, "shell", "ip", "route")
+	if err != nil {
+		return "", err
+	}
+	if ip := parseRouteSource(string(out)); len(ip) > 0 {
+		return ip, nil
+	}
+	return "", errors.New("cannot find wifi address, is the device connected to Wi-Fi?")
+}
+
+// 解析 ip route 的输出，返回 wlan 网卡的 src 地址，没有时返回空字符串：
+//
+//	192.168.1.0/24 dev wlan0 proto kernel scope link src 192.168.1.23
+//
+// 移动网络、有线网络的地址电脑通常无法访问，不作为候选
+func parseRouteSource(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		var dev, src string
+		for i := 0; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "dev":
+				dev = fields[i+1]
+			case "src":
+				src = fields[i+1]
+			}
+		}
+		if len(src) > 0 && strings.HasPrefix(dev, "wlan") {
+			return src
+		}
+	}
+	return ""
+}
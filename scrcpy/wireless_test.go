@@ -0,0 +1,30 @@
+package scrcpy
+
+import "testing"
+
+func TestParseRouteSource(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want string
+	}{
+		{"wlan", "192.168.1.0/24 dev wlan0 proto kernel scope link src 192.168.1.23 \n", "192.168.1.23"},
+		{"crlf", "192.168.1.0/24 dev wlan1 proto kernel scope link src 192.168.1.24\r\n", "192.168.1.24"},
+		// 同时连着移动网络时跳过移动网络
+		{"wlan after mobile", `10.117.20.0/27 dev rmnet_data0 proto kernel scope link src 10.117.20.5
+192.168.1.0/24 dev wlan0 proto kernel scope link src 192.168.1.23
+`, "192.168.1.23"},
+		{"mobile only", "10.117.20.0/27 dev rmnet_data0 proto kernel scope link src 10.117.20.5\n", ""},
+		{"ethernet", "192.168.50.0/24 dev eth0 proto kernel scope link src 192.168.50.7\r\n", ""},
+		{"no source", "default via 192.168.1.1 dev wlan0 proto static\n", ""},
+		{"src at end of line", "192.168.1.0/24 dev wlan0 src\n", ""},
+		{"empty", "", ""},
+		{"error", "Error: ipv4: FIB table does not exist.\nDump terminated\n", ""},
+	}
+
+	for _, tt := range tests {
+		if got := parseRouteSource(tt.out); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
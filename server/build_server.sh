@@ -0,0 +1,38 @@
+#!/bin/sh
+# 由 server/ 中的源码构建 res/scrcpy-server.jar（需要 Android SDK 及 gradle）。
+# 加 --check 时只检查 res/scrcpy-server.jar 是否包含客户端依赖的功能，不构建
+set -e
+
+cd "$(dirname "$0")"
+JAR=../res/scrcpy-server.jar
+
+# 每行一个功能及其在 classes.dex 中特有的名称
+FEATURES='handshake Lcom/genymobile/scrcpy/Protocol;
+device-messages Lcom/genymobile/scrcpy/DeviceMessageWriter;
+scroll-injection scrollProperties'
+
+check() {
+    missing=
+    dex=$(mktemp)
+    unzip -p "$1" classes.dex > "$dex"
+    while read -r feature name; do
+        grep -aq "$name" "$dex" || missing="$missing $feature"
+    done <<END
+$FEATURES
+END
+    rm -f "$dex"
+    if [ -n "$missing" ]; then
+        echo "$1: built from sources older than server/ (missing:$missing), rebuild it with $0" >&2
+        return 1
+    fi
+    echo "$1: ok"
+}
+
+if [ "$1" = "--check" ]; then
+    check "$JAR"
+    exit
+fi
+
+gradle -q :server:assembleDebug
+cp server/build/outputs/apk/debug/server-debug.apk "$JAR"
+check "$JAR"
@@ -24,6 +24,7 @@ public final class ControlEvent {
     private Size screenSize;
     private Point[] points;
     private int[] ids;
+    private Position position;
     private int hScroll;
     private int vScroll;
 
@@ -61,7 +62,7 @@ public final class ControlEvent {
     public static ControlEvent createScrollControlEvent(Position position, int hScroll, int vScroll) {
         ControlEvent event = new ControlEvent();
         event.type = TYPE_SCROLL;
-//        event.position = position;
+        event.position = position;
         event.hScroll = hScroll;
         event.vScroll = vScroll;
         return event;
@@ -99,7 +100,7 @@ public final class ControlEvent {
     }
 
     public Position getPosition() {
-        return null;
+        return position;
     }
 
     public int getHScroll() {
@@ -118,7 +118,8 @@ public class ControlEventReader {
     }
 
     private ControlEvent parseTextControlEvent() {
-        if (buffer.remaining() < 1) {
+        // the length is a short, it may be split across two reads
+        if (buffer.remaining() < 2) {
             return null;
         }
         int len = toUnsigned(buffer.getShort());
@@ -8,6 +8,7 @@ import java.io.Closeable;
 import java.io.FileDescriptor;
 import java.io.IOException;
 import java.io.InputStream;
+import java.io.OutputStream;
 import java.lang.reflect.Field;
 import java.net.Socket;
 import java.net.SocketImpl;
@@ -24,6 +25,15 @@ public final class DesktopConnection implements Closeable {
     private InputStream inputStream;
     private FileDescriptor fd;
 
+    // optional second socket through the same tunnel, for device-to-client messages
+    private boolean deviceMessages;
+    private LocalSocket localMessageSock;
+    private Socket tcpMessageSock;
+    // in forward mode, kept open until the message socket is accepted
+    private LocalServerSocket messageServerSocket;
+    private String host;
+    private int port;
+
     private final ControlEventReader reader = new ControlEventReader();
 
     private DesktopConnection(LocalSocket sock) throws IOException {
@@ -53,39 +63,71 @@ public final class DesktopConnection implements Closeable {
         return localSocket;
     }
 
-    private static LocalSocket listenAndAccept(String abstractName) throws IOException {
-        LocalServerSocket localServerSocket = new LocalServerSocket(abstractName);
-        try {
-            return localServerSocket.accept();
-        } finally {
-            localServerSocket.close();
-        }
+    private static LocalSocket acceptAndWriteByte(LocalServerSocket localServerSocket) throws IOException {
+        LocalSocket socket = localServerSocket.accept();
+        // send one byte so the client may read() to detect a connection error
+        socket.getOutputStream().write(0);
+        return socket;
     }
 
-    public static DesktopConnection open(Device device, boolean tunnelForward) throws IOException {
+    public static DesktopConnection open(boolean tunnelForward, boolean deviceMessages) throws IOException {
         LocalSocket socket;
+        LocalServerSocket messageServerSocket = null;
         if (tunnelForward) {
-            socket = listenAndAccept(SOCKET_NAME);
-            // send one byte so the client may read() to detect a connection error
-            socket.getOutputStream().write(0);
+            LocalServerSocket localServerSocket = new LocalServerSocket(SOCKET_NAME);
+            try {
+                socket = acceptAndWriteByte(localServerSocket);
+                if (deviceMessages) {
+                    messageServerSocket = localServerSocket;
+                }
+            } finally {
+                if (messageServerSocket == null) {
+                    localServerSocket.close();
+                }
+            }
         } else {
             socket = connect(SOCKET_NAME);
         }
 
         DesktopConnection connection = new DesktopConnection(socket);
-        Size videoSize = device.getScreenInfo().getVideoSize();
-        connection.send(Device.getDeviceName(), videoSize.getWidth(), videoSize.getHeight());
+        connection.deviceMessages = deviceMessages;
+        connection.messageServerSocket = messageServerSocket;
         return connection;
     }
 
-    public static DesktopConnection open(Device device, String host, int port) throws IOException, NoSuchFieldException, IllegalAccessException {
+    public static DesktopConnection open(String host, int port, boolean deviceMessages)
+            throws IOException, NoSuchFieldException, IllegalAccessException {
         Socket socket = new Socket(host, port);
         DesktopConnection connection = new DesktopConnection(socket);
-        Size videoSize = device.getScreenInfo().getVideoSize();
-        connection.send(Device.getDeviceName(), videoSize.getWidth(), videoSize.getHeight());
+        connection.deviceMessages = deviceMessages;
+        connection.host = host;
+        connection.port = port;
         return connection;
     }
 
+    /**
+     * Open the socket for device messages, if the client asked for it.
+     * <p>
+     * It is opened only after the device info, so that the client knows from the handshake whether to wait for it.
+     */
+    public void openMessageSocket() throws IOException {
+        if (!deviceMessages) {
+            return;
+        }
+        if (messageServerSocket != null) {
+            try {
+                localMessageSock = acceptAndWriteByte(messageServerSocket);
+            } finally {
+                messageServerSocket.close();
+                messageServerSocket = null;
+            }
+        } else if (host != null) {
+            tcpMessageSock = new Socket(host, port);
+        } else {
+            localMessageSock = connect(SOCKET_NAME);
+        }
+    }
+
     public void close() throws IOException {
         if (localSock != null) {
             localSock.shutdownInput();
@@ -96,6 +138,28 @@ public final class DesktopConnection implements Closeable {
             tcpSock.shutdownOutput();
             tcpSock.close();
         }
+        if (localMessageSock != null) {
+            localMessageSock.close();
+        } else if (tcpMessageSock != null) {
+            tcpMessageSock.close();
+        }
+        if (messageServerSocket != null) {
+            messageServerSocket.close();
+        }
+    }
+
+    /**
+     * Send the device info, preceded by the handshake header if the client passed its protocol version.
+     * <p>
+     * Old clients do not pass any version and only expect the device info.
+     */
+    public void sendDeviceInfo(Device device, int clientProtocolVersion) throws IOException {
+        if (clientProtocolVersion > 0) {
+            byte[] header = Protocol.serializeHeader(Protocol.getSupportedCodecs());
+            IO.writeFully(fd, header, 0, header.length);
+        }
+        Size videoSize = device.getScreenInfo().getVideoSize();
+        send(Device.getDeviceName(), videoSize.getWidth(), videoSize.getHeight());
     }
 
     @SuppressWarnings("checkstyle:MagicNumber")
@@ -118,6 +182,19 @@ public final class DesktopConnection implements Closeable {
         return fd;
     }
 
+    /**
+     * Return the stream for device messages, or {@code null} if the client did not ask for them.
+     */
+    public OutputStream getMessageStream() throws IOException {
+        if (localMessageSock != null) {
+            return localMessageSock.getOutputStream();
+        }
+        if (tcpMessageSock != null) {
+            return tcpMessageSock.getOutputStream();
+        }
+        return null;
+    }
+
     public ControlEvent receiveControlEvent() throws IOException {
         ControlEvent event = reader.next();
         while (event == null) {
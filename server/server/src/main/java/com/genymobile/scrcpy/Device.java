@@ -1,5 +1,6 @@
 package com.genymobile.scrcpy;
 
+import android.content.IOnPrimaryClipChangedListener;
 import android.graphics.Point;
 import android.graphics.Rect;
 import android.os.Build;
@@ -145,6 +146,15 @@ public final class Device {
         serviceManager.getWindowManager().registerRotationWatcher(rotationWatcher);
     }
 
+    public String getClipboardText() {
+        CharSequence s = serviceManager.getClipboardManager().getText();
+        return s == null ? null : s.toString();
+    }
+
+    public void registerClipboardListener(IOnPrimaryClipChangedListener listener) {
+        serviceManager.getClipboardManager().addPrimaryClipChangedListener(listener);
+    }
+
     public synchronized void setRotationListener(RotationListener rotationListener) {
         this.rotationListener = rotationListener;
     }
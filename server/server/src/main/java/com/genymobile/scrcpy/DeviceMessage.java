@@ -0,0 +1,51 @@
+package com.genymobile.scrcpy;
+
+/**
+ * Union of all messages sent from the device to the client, identified by their {@code type}.
+ */
+public final class DeviceMessage {
+
+    public static final int TYPE_CLIPBOARD = 0;
+    public static final int TYPE_ROTATION = 1;
+    public static final int TYPE_ERROR = 2;
+
+    private int type;
+    private String text;
+    private int rotation;
+
+    private DeviceMessage() {
+    }
+
+    public static DeviceMessage createClipboard(String text) {
+        DeviceMessage msg = new DeviceMessage();
+        msg.type = TYPE_CLIPBOARD;
+        msg.text = text;
+        return msg;
+    }
+
+    public static DeviceMessage createRotation(int rotation) {
+        DeviceMessage msg = new DeviceMessage();
+        msg.type = TYPE_ROTATION;
+        msg.rotation = rotation;
+        return msg;
+    }
+
+    public static DeviceMessage createError(String text) {
+        DeviceMessage msg = new DeviceMessage();
+        msg.type = TYPE_ERROR;
+        msg.text = text;
+        return msg;
+    }
+
+    public int getType() {
+        return type;
+    }
+
+    public String getText() {
+        return text;
+    }
+
+    public int getRotation() {
+        return rotation;
+    }
+}
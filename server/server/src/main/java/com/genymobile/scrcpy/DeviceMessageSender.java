@@ -0,0 +1,39 @@
+package com.genymobile.scrcpy;
+
+import java.io.IOException;
+import java.io.OutputStream;
+import java.util.concurrent.ArrayBlockingQueue;
+import java.util.concurrent.BlockingQueue;
+
+/**
+ * Queue device messages from any thread and write them to the message socket from a single thread.
+ */
+public class DeviceMessageSender {
+
+    private static final int QUEUE_CAPACITY = 64;
+
+    private final OutputStream output;
+    private final DeviceMessageWriter writer = new DeviceMessageWriter();
+    private final BlockingQueue<DeviceMessage> queue = new ArrayBlockingQueue<>(QUEUE_CAPACITY);
+
+    public DeviceMessageSender(OutputStream output) {
+        this.output = output;
+    }
+
+    public void send(DeviceMessage msg) {
+        // never block the caller (binder threads, the event controller): drop if the client does not read
+        if (!queue.offer(msg)) {
+            Ln.w("Device message queue full, message dropped");
+        }
+    }
+
+    public void loop() throws IOException {
+        try {
+            while (true) {
+                writer.writeTo(queue.take(), output);
+            }
+        } catch (InterruptedException e) {
+            // stopped
+        }
+    }
+}
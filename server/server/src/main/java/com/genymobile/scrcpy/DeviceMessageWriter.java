@@ -0,0 +1,59 @@
+package com.genymobile.scrcpy;
+
+import java.io.IOException;
+import java.io.OutputStream;
+import java.nio.ByteBuffer;
+import java.nio.charset.StandardCharsets;
+
+/**
+ * Serialize device messages as frames: type (1 byte), payload length (4 bytes, big-endian), payload.
+ * <p>
+ * The length prefix lets the client skip message types it does not know.
+ */
+public class DeviceMessageWriter {
+
+    private static final int HEADER_LENGTH = 5;
+    public static final int PAYLOAD_MAX_LENGTH = 1 << 18;
+
+    public byte[] serialize(DeviceMessage msg) {
+        byte[] payload;
+        switch (msg.getType()) {
+            case DeviceMessage.TYPE_CLIPBOARD:
+            case DeviceMessage.TYPE_ERROR:
+                payload = utf8Truncate(msg.getText(), PAYLOAD_MAX_LENGTH);
+                break;
+            case DeviceMessage.TYPE_ROTATION:
+                payload = new byte[] {(byte) msg.getRotation()};
+                break;
+            default:
+                throw new IllegalArgumentException("Unknown device message type: " + msg.getType());
+        }
+
+        ByteBuffer buffer = ByteBuffer.allocate(HEADER_LENGTH + payload.length);
+        buffer.put((byte) msg.getType());
+        buffer.putInt(payload.length);
+        buffer.put(payload);
+        return buffer.array();
+    }
+
+    public void writeTo(DeviceMessage msg, OutputStream output) throws IOException {
+        output.write(serialize(msg));
+        output.flush();
+    }
+
+    @SuppressWarnings("checkstyle:MagicNumber")
+    private static byte[] utf8Truncate(String text, int maxLength) {
+        byte[] bytes = text.getBytes(StandardCharsets.UTF_8);
+        if (bytes.length <= maxLength) {
+            return bytes;
+        }
+        // do not cut a multi-byte character in the middle
+        int len = maxLength;
+        while (len > 0 && (bytes[len] & 0xc0) == 0x80) {
+            len--;
+        }
+        byte[] truncated = new byte[len];
+        System.arraycopy(bytes, 0, truncated, 0, len);
+        return truncated;
+    }
+}
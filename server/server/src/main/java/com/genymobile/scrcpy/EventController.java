@@ -18,14 +18,16 @@ public class EventController {
 
     private final Device device;
     private final DesktopConnection connection;
+    private final DeviceMessageSender sender; // null if the client does not read device messages
 
     private final KeyCharacterMap charMap = KeyCharacterMap.load(KeyCharacterMap.VIRTUAL_KEYBOARD);
 
     private long lastMouseDown;
 
-    public EventController(Device device, DesktopConnection connection) {
+    public EventController(Device device, DesktopConnection connection, DeviceMessageSender sender) {
         this.device = device;
         this.connection = connection;
+        this.sender = sender;
         initPointer();
     }
 
@@ -63,6 +65,14 @@ public class EventController {
             pointerProperties8[i].toolType = MotionEvent.TOOL_TYPE_FINGER;
         }
 
+        // scroll events use their own arrays, so that the scroll axes never leak into touch events
+        scrollProperties[0] = new MotionEvent.PointerProperties();
+        scrollProperties[0].toolType = MotionEvent.TOOL_TYPE_MOUSE;
+        scrollCoords[0] = new MotionEvent.PointerCoords();
+        scrollCoords[0].orientation = 0;
+        scrollCoords[0].pressure = 1;
+        scrollCoords[0].size = 1;
+
         MotionEvent.PointerCoords coords;
         for (int i = 0; i < 1; i++) {
             pointerCoords1[i] = new MotionEvent.PointerCoords();
@@ -122,17 +132,13 @@ public class EventController {
         }
     }
 
-//    private void setPointerCoords(Point point) {
-//        MotionEvent.PointerCoords coords = pointerCoords[0];
-//        coords.x = point.x;
-//        coords.y = point.y;
-//    }
-
-//    private void setScroll(int hScroll, int vScroll) {
-//        MotionEvent.PointerCoords coords = pointerCoords[0];
-//        coords.setAxisValue(MotionEvent.AXIS_HSCROLL, hScroll);
-//        coords.setAxisValue(MotionEvent.AXIS_VSCROLL, vScroll);
-//    }
+    private void setScroll(Point point, int hScroll, int vScroll) {
+        MotionEvent.PointerCoords coords = scrollCoords[0];
+        coords.x = point.x;
+        coords.y = point.y;
+        coords.setAxisValue(MotionEvent.AXIS_HSCROLL, hScroll);
+        coords.setAxisValue(MotionEvent.AXIS_VSCROLL, vScroll);
+    }
 
     public void control() throws IOException {
         // on start, turn screen on
@@ -232,9 +238,8 @@ public class EventController {
             // ignore event
             return false;
         }
-//        setPointerCoords(point);
-//        setScroll(hScroll, vScroll);
-        MotionEvent event = MotionEvent.obtain(lastMouseDown, now, MotionEvent.ACTION_SCROLL, 1, pointerProperties1, pointerCoords1, 0, 0, 1f, 1f, 0,
+        setScroll(point, hScroll, vScroll);
+        MotionEvent event = MotionEvent.obtain(lastMouseDown, now, MotionEvent.ACTION_SCROLL, 1, scrollProperties, scrollCoords, 0, 0, 1f, 1f, 0,
                 0, InputDevice.SOURCE_MOUSE, 0);
         return injectEvent(event);
     }
@@ -270,10 +275,20 @@ public class EventController {
                 return pressBackOrTurnScreenOn();
             default:
                 Ln.w("Unsupported command: " + action);
+                sendError("Unsupported command: " + action);
         }
         return false;
     }
 
+    private void sendError(String text) {
+        if (sender != null) {
+            sender.send(DeviceMessage.createError(text));
+        }
+    }
+
+    private final MotionEvent.PointerProperties[] scrollProperties = new MotionEvent.PointerProperties[1];
+    private final MotionEvent.PointerCoords[] scrollCoords = new MotionEvent.PointerCoords[1];
+
     private final MotionEvent.PointerProperties[] pointerProperties0 = new MotionEvent.PointerProperties[0];
     private final MotionEvent.PointerProperties[] pointerProperties1 = new MotionEvent.PointerProperties[1];
     private final MotionEvent.PointerProperties[] pointerProperties2 = new MotionEvent.PointerProperties[2];
@@ -9,6 +9,8 @@ public class Options {
 //    private Point correctedValue;
     private String host;
     private int port;
+    private boolean deviceMessages; // open a second socket for device-to-client messages
+    private int protocolVersion; // 0 for clients without a versioned handshake
 
 //    public int getMaxSize() {
 //        return maxSize;
@@ -73,4 +75,20 @@ public class Options {
     public void setPort(int port) {
         this.port = port;
     }
+
+    public boolean getDeviceMessages() {
+        return deviceMessages;
+    }
+
+    public void setDeviceMessages(boolean deviceMessages) {
+        this.deviceMessages = deviceMessages;
+    }
+
+    public int getProtocolVersion() {
+        return protocolVersion;
+    }
+
+    public void setProtocolVersion(int protocolVersion) {
+        this.protocolVersion = protocolVersion;
+    }
 }
@@ -0,0 +1,73 @@
+package com.genymobile.scrcpy;
+
+import android.media.MediaCodecInfo;
+import android.media.MediaCodecList;
+import android.media.MediaFormat;
+
+import java.nio.ByteBuffer;
+import java.nio.charset.StandardCharsets;
+
+/**
+ * Handshake header sent before the device info, if the client passed its protocol version.
+ * <p>
+ * Layout: magic (4 bytes), version (2 bytes), capabilities length (2 bytes), capabilities. New capabilities are only
+ * ever appended, so that a client may skip the ones it does not know.
+ */
+public final class Protocol {
+
+    public static final int VERSION = 1;
+
+    public static final byte[] MAGIC = "SCGO".getBytes(StandardCharsets.US_ASCII);
+
+    public static final int CODEC_H264 = 1;
+    public static final int CODEC_H265 = 1 << 1;
+
+    // the input dispatcher rejects motion events with more pointers (MAX_POINTERS in input.h)
+    public static final int MAX_POINTERS = 16;
+
+    public static final int SUPPORTED_EVENT_TYPES = 1 << ControlEvent.TYPE_KEYCODE
+            | 1 << ControlEvent.TYPE_TEXT
+            | 1 << ControlEvent.TYPE_MOUSE
+            | 1 << ControlEvent.TYPE_SCROLL
+            | 1 << ControlEvent.TYPE_COMMAND;
+
+    // max pointers (1 byte), event types (4 bytes), codecs (4 bytes)
+    private static final int CAPABILITIES_LENGTH = 9;
+
+    private Protocol() {
+        // not instantiable
+    }
+
+    @SuppressWarnings("checkstyle:MagicNumber")
+    public static byte[] serializeHeader(int codecs) {
+        ByteBuffer buffer = ByteBuffer.allocate(MAGIC.length + 4 + CAPABILITIES_LENGTH);
+        buffer.put(MAGIC);
+        buffer.putShort((short) VERSION);
+        buffer.putShort((short) CAPABILITIES_LENGTH);
+        buffer.put((byte) MAX_POINTERS);
+        buffer.putInt(SUPPORTED_EVENT_TYPES);
+        buffer.putInt(codecs);
+        return buffer.array();
+    }
+
+    /**
+     * Return the codecs the device can encode, as a mask of {@code CODEC_*}.
+     */
+    public static int getSupportedCodecs() {
+        int codecs = 0;
+        MediaCodecInfo[] infos = new MediaCodecList(MediaCodecList.REGULAR_CODECS).getCodecInfos();
+        for (MediaCodecInfo info : infos) {
+            if (!info.isEncoder()) {
+                continue;
+            }
+            for (String type : info.getSupportedTypes()) {
+                if (MediaFormat.MIMETYPE_VIDEO_AVC.equalsIgnoreCase(type)) {
+                    codecs |= CODEC_H264;
+                } else if (MediaFormat.MIMETYPE_VIDEO_HEVC.equalsIgnoreCase(type)) {
+                    codecs |= CODEC_H265;
+                }
+            }
+        }
+        return codecs;
+    }
+}
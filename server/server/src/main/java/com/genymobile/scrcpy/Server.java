@@ -1,6 +1,10 @@
 package com.genymobile.scrcpy;
 
+import android.content.IOnPrimaryClipChangedListener;
+import android.view.IRotationWatcher;
+
 import java.io.IOException;
+import java.io.OutputStream;
 
 public final class Server {
 
@@ -12,22 +16,28 @@ public final class Server {
         final Device device = new Device(options);
 
         if (options.getHost() != null) {
-            try (DesktopConnection connection = DesktopConnection.open(device, options.getHost(), options.getPort())) {
+            try (DesktopConnection connection = DesktopConnection.open(options.getHost(), options.getPort(), options.getDeviceMessages())) {
                 startServerInner(options, device, connection);
             }
         } else {
             boolean tunnelForward = options.isTunnelForward();
-            try (DesktopConnection connection = DesktopConnection.open(device, tunnelForward)) {
+            try (DesktopConnection connection = DesktopConnection.open(tunnelForward, options.getDeviceMessages())) {
                 startServerInner(options, device, connection);
             }
         }
     }
 
-    private static void startServerInner(Options options, Device device, DesktopConnection connection) {
+    private static void startServerInner(Options options, Device device, DesktopConnection connection) throws IOException {
+        connection.sendDeviceInfo(device, options.getProtocolVersion());
+        // old clients do not send their protocol version and never connect the message socket
+        if (options.getProtocolVersion() > 0) {
+            connection.openMessageSocket();
+        }
         ScreenEncoder screenEncoder = new ScreenEncoder(options.getSendFrameMeta(), options.getBitRate());
 
         // asynchronous
-        startEventController(device, connection);
+        DeviceMessageSender sender = startDeviceMessageSender(device, connection);
+        startEventController(device, connection, sender);
 
         try {
             // synchronous
@@ -38,12 +48,12 @@ public final class Server {
         }
     }
 
-    private static void startEventController(final Device device, final DesktopConnection connection) {
+    private static void startEventController(final Device device, final DesktopConnection connection, final DeviceMessageSender sender) {
         new Thread(new Runnable() {
             @Override
             public void run() {
                 try {
-                    new EventController(device, connection).control();
+                    new EventController(device, connection, sender).control();
                 } catch (IOException e) {
                     // this is expected on close
                     Ln.d("Event controller stopped");
@@ -52,6 +62,55 @@ public final class Server {
         }).start();
     }
 
+    /**
+     * Return {@code null} if the client did not open the message socket.
+     */
+    private static DeviceMessageSender startDeviceMessageSender(final Device device, DesktopConnection connection) throws IOException {
+        OutputStream output = connection.getMessageStream();
+        if (output == null) {
+            return null;
+        }
+
+        final DeviceMessageSender sender = new DeviceMessageSender(output);
+        Thread thread = new Thread(new Runnable() {
+            @Override
+            public void run() {
+                try {
+                    sender.loop();
+                } catch (IOException e) {
+                    // this is expected on close
+                    Ln.d("Device message sender stopped");
+                }
+            }
+        });
+        // waiting for the next message must not keep the server alive once the connection is closed
+        thread.setDaemon(true);
+        thread.start();
+
+        device.registerRotationWatcher(new IRotationWatcher.Stub() {
+            @Override
+            public void onRotationChanged(int rotation) {
+                sender.send(DeviceMessage.createRotation(rotation));
+            }
+        });
+        try {
+            device.registerClipboardListener(new IOnPrimaryClipChangedListener.Stub() {
+                @Override
+                public void dispatchPrimaryClipChanged() {
+                    String text = device.getClipboardText();
+                    if (text != null) {
+                        sender.send(DeviceMessage.createClipboard(text));
+                    }
+                }
+            });
+        } catch (AssertionError e) {
+            // the clipboard service API differs between Android versions, the other messages still work
+            Ln.w("Could not listen to the clipboard: " + e.getCause());
+            sender.send(DeviceMessage.createError("clipboard sync is not supported on this device"));
+        }
+        return sender;
+    }
+
     @SuppressWarnings("checkstyle:MagicNumber")
     private static Options createOptions(String... args) {
         Options options = new Options();
@@ -104,6 +163,19 @@ public final class Server {
 //        Point p = parsePoint(args[5]);
 //        options.setCorrectedValue(p);
 
+        if (args.length < 4) {
+            return options;
+        }
+        boolean deviceMessages = Boolean.parseBoolean(args[3]);
+        options.setDeviceMessages(deviceMessages);
+
+        if (args.length < 5) {
+            return options;
+        }
+        // the version the client speaks, the handshake header is only sent to clients which pass it
+        int protocolVersion = Integer.parseInt(args[4]);
+        options.setProtocolVersion(protocolVersion);
+
         return options;
     }
 
@@ -0,0 +1,65 @@
+package com.genymobile.scrcpy.wrappers;
+
+import android.content.ClipData;
+import android.content.IOnPrimaryClipChangedListener;
+import android.os.IInterface;
+
+public final class ClipboardManager {
+    // the server runs as the shell user
+    private static final String PACKAGE_NAME = "com.android.shell";
+    private static final int USER_ID = 0;
+
+    private final IInterface manager;
+
+    public ClipboardManager(IInterface manager) {
+        this.manager = manager;
+    }
+
+    public CharSequence getText() {
+        ClipData clipData = getPrimaryClip();
+        if (clipData == null || clipData.getItemCount() == 0) {
+            return null;
+        }
+        return clipData.getItemAt(0).getText();
+    }
+
+    private ClipData getPrimaryClip() {
+        try {
+            Class<?> cls = manager.getClass();
+            try {
+                return (ClipData) cls.getMethod("getPrimaryClip", String.class).invoke(manager, PACKAGE_NAME);
+            } catch (NoSuchMethodException e) {
+                try {
+                    // user id added in Android 10
+                    return (ClipData) cls.getMethod("getPrimaryClip", String.class, int.class).invoke(manager, PACKAGE_NAME, USER_ID);
+                } catch (NoSuchMethodException e2) {
+                    // attribution tag added in Android 12
+                    return (ClipData) cls.getMethod("getPrimaryClip", String.class, String.class, int.class)
+                            .invoke(manager, PACKAGE_NAME, null, USER_ID);
+                }
+            }
+        } catch (Exception e) {
+            throw new AssertionError(e);
+        }
+    }
+
+    public void addPrimaryClipChangedListener(IOnPrimaryClipChangedListener listener) {
+        try {
+            Class<?> cls = manager.getClass();
+            try {
+                cls.getMethod("addPrimaryClipChangedListener", IOnPrimaryClipChangedListener.class, String.class)
+                        .invoke(manager, listener, PACKAGE_NAME);
+            } catch (NoSuchMethodException e) {
+                try {
+                    cls.getMethod("addPrimaryClipChangedListener", IOnPrimaryClipChangedListener.class, String.class, int.class)
+                            .invoke(manager, listener, PACKAGE_NAME, USER_ID);
+                } catch (NoSuchMethodException e2) {
+                    cls.getMethod("addPrimaryClipChangedListener", IOnPrimaryClipChangedListener.class, String.class, String.class,
+                            int.class).invoke(manager, listener, PACKAGE_NAME, null, USER_ID);
+                }
+            }
+        } catch (Exception e) {
+            throw new AssertionError(e);
+        }
+    }
+}
@@ -14,6 +14,7 @@ public final class ServiceManager {
     private DisplayManager displayManager;
     private InputManager inputManager;
     private PowerManager powerManager;
+    private ClipboardManager clipboardManager;
 
     public ServiceManager() {
         try {
@@ -60,4 +61,11 @@ public final class ServiceManager {
         }
         return powerManager;
     }
+
+    public ClipboardManager getClipboardManager() {
+        if (clipboardManager == null) {
+            clipboardManager = new ClipboardManager(getService("clipboard", "android.content.IClipboard"));
+        }
+        return clipboardManager;
+    }
 }
@@ -56,6 +56,29 @@ public class ControlEventReaderTest {
         Assert.assertEquals("testé", event.getText());
     }
 
+    @Test
+    public void testParseSplitTextEvent() throws IOException {
+        ControlEventReader reader = new ControlEventReader();
+
+        ByteArrayOutputStream bos = new ByteArrayOutputStream();
+        DataOutputStream dos = new DataOutputStream(bos);
+        dos.writeByte(ControlEvent.TYPE_TEXT);
+        byte[] text = "testé".getBytes(StandardCharsets.UTF_8);
+        dos.writeShort(text.length);
+        dos.write(text);
+        byte[] packet = bos.toByteArray();
+
+        // only the first byte of the length is received
+        reader.readFrom(new ByteArrayInputStream(packet, 0, 2));
+        Assert.assertNull(reader.next()); // the event is not complete
+
+        reader.readFrom(new ByteArrayInputStream(packet, 2, packet.length - 2));
+        ControlEvent event = reader.next();
+
+        Assert.assertEquals(ControlEvent.TYPE_TEXT, event.getType());
+        Assert.assertEquals("testé", event.getText());
+    }
+
     @Test
     public void testParseLongTextEvent() throws IOException {
         ControlEventReader reader = new ControlEventReader();
@@ -97,6 +120,39 @@ public class ControlEventReaderTest {
         Assert.assertEquals(KeyEvent.META_CTRL_ON, event.getMetaState());
     }
 
+    @Test
+    public void testParseScrollEvent() throws IOException {
+        ControlEventReader reader = new ControlEventReader();
+
+        ByteArrayOutputStream bos = new ByteArrayOutputStream();
+        DataOutputStream dos = new DataOutputStream(bos);
+        dos.writeByte(ControlEvent.TYPE_SCROLL);
+        dos.writeShort(260);
+        dos.writeShort(1026);
+        dos.writeShort(1080);
+        dos.writeShort(1920);
+        dos.writeInt(1);
+        dos.writeInt(-1);
+
+        // the scroll payload must be consumed entirely
+        dos.writeByte(ControlEvent.TYPE_COMMAND);
+        dos.writeByte(ControlEvent.COMMAND_BACK_OR_SCREEN_ON);
+        byte[] packet = bos.toByteArray();
+
+        reader.readFrom(new ByteArrayInputStream(packet));
+        ControlEvent event = reader.next();
+
+        Assert.assertEquals(ControlEvent.TYPE_SCROLL, event.getType());
+        Assert.assertNotNull(event.getPosition());
+        Assert.assertEquals(new Size(1080, 1920), event.getPosition().getScreenSize());
+        Assert.assertEquals(1, event.getHScroll());
+        Assert.assertEquals(-1, event.getVScroll());
+
+        event = reader.next();
+        Assert.assertEquals(ControlEvent.TYPE_COMMAND, event.getType());
+        Assert.assertEquals(ControlEvent.COMMAND_BACK_OR_SCREEN_ON, event.getAction());
+    }
+
     @Test
     public void testMultiEvents() throws IOException {
         ControlEventReader reader = new ControlEventReader();
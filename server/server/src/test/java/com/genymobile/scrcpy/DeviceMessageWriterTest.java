@@ -0,0 +1,59 @@
+package com.genymobile.scrcpy;
+
+import org.junit.Assert;
+import org.junit.Test;
+
+import java.io.ByteArrayOutputStream;
+import java.io.DataOutputStream;
+import java.io.IOException;
+import java.nio.charset.StandardCharsets;
+
+
+public class DeviceMessageWriterTest {
+
+    @Test
+    public void testSerializeClipboard() throws IOException {
+        DeviceMessageWriter writer = new DeviceMessageWriter();
+
+        DeviceMessage msg = DeviceMessage.createClipboard("aéb");
+        byte[] text = "aéb".getBytes(StandardCharsets.UTF_8);
+
+        ByteArrayOutputStream bos = new ByteArrayOutputStream();
+        DataOutputStream dos = new DataOutputStream(bos);
+        dos.writeByte(DeviceMessage.TYPE_CLIPBOARD);
+        dos.writeInt(text.length);
+        dos.write(text);
+
+        Assert.assertArrayEquals(bos.toByteArray(), writer.serialize(msg));
+    }
+
+    @Test
+    public void testSerializeRotation() throws IOException {
+        DeviceMessageWriter writer = new DeviceMessageWriter();
+
+        ByteArrayOutputStream bos = new ByteArrayOutputStream();
+        DataOutputStream dos = new DataOutputStream(bos);
+        dos.writeByte(DeviceMessage.TYPE_ROTATION);
+        dos.writeInt(1);
+        dos.writeByte(3);
+
+        Assert.assertArrayEquals(bos.toByteArray(), writer.serialize(DeviceMessage.createRotation(3)));
+    }
+
+    @Test
+    public void testSerializeLongTextTruncated() {
+        DeviceMessageWriter writer = new DeviceMessageWriter();
+
+        // 'é' takes 2 bytes, the limit falls in the middle of the last one
+        StringBuilder builder = new StringBuilder();
+        for (int i = 0; i < DeviceMessageWriter.PAYLOAD_MAX_LENGTH / 2 + 1; i++) {
+            builder.append('é');
+        }
+        builder.insert(0, 'a');
+
+        byte[] packet = writer.serialize(DeviceMessage.createError(builder.toString()));
+        int length = packet.length - 5;
+        Assert.assertEquals(DeviceMessageWriter.PAYLOAD_MAX_LENGTH - 1, length);
+        Assert.assertEquals(length, ((packet[1] & 0xff) << 24) | ((packet[2] & 0xff) << 16) | ((packet[3] & 0xff) << 8) | (packet[4] & 0xff));
+    }
+}
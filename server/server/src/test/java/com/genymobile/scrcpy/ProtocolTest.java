@@ -0,0 +1,26 @@
+package com.genymobile.scrcpy;
+
+import org.junit.Assert;
+import org.junit.Test;
+
+import java.io.ByteArrayOutputStream;
+import java.io.DataOutputStream;
+import java.io.IOException;
+
+
+public class ProtocolTest {
+
+    @Test
+    public void testSerializeHeader() throws IOException {
+        ByteArrayOutputStream bos = new ByteArrayOutputStream();
+        DataOutputStream dos = new DataOutputStream(bos);
+        dos.writeBytes("SCGO");
+        dos.writeShort(Protocol.VERSION);
+        dos.writeShort(9);
+        dos.writeByte(Protocol.MAX_POINTERS);
+        dos.writeInt(0b11111); // keycode, text, mouse, scroll, command
+        dos.writeInt(Protocol.CODEC_H264);
+
+        Assert.assertArrayEquals(bos.toByteArray(), Protocol.serializeHeader(Protocol.CODEC_H264));
+    }
+}